@@ -0,0 +1,178 @@
+package rollinglog
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Archiver stores backup before sweeping removes it. Backup removed only
+// when Archive succeeded.
+type Archiver interface {
+	Archive(aPath string) error
+}
+
+// ArchiverFunc is an adapter to allow the use of ordinary functions as Archiver
+type ArchiverFunc func(aPath string) error
+
+// Archive calls f(aPath)
+func (f ArchiverFunc) Archive(aPath string) error {
+	return f(aPath)
+}
+
+// DirArchiver copies backups into aDir, e.g. mounted network share. Archived
+// files are never overwritten: sequence number added to name of backup when
+// file with the same name exists (e.g. numbered backups).
+func DirArchiver(aDir string) Archiver {
+	return ArchiverFunc(func(aPath string) error {
+		return copyFile(aPath, uniqueArchiveName(aDir, filepath.Base(aPath)))
+	})
+}
+
+// uniqueArchiveName returns path in aDir for backup aName which doesn't clash
+// with archived files. Sequence number inserted before extension.
+func uniqueArchiveName(aDir, aName string) string {
+	suffix := ""
+	for _, s := range compressedSuffixes() {
+		if strings.HasSuffix(aName, s) {
+			aName, suffix = strings.TrimSuffix(aName, s), s
+			break
+		}
+	}
+	name, ext := splitExt(aName)
+
+	for seq := 0; ; seq++ {
+		path := filepath.Join(aDir, name+seqSuffix(seq)+ext+suffix)
+		if _, err := os.Lstat(path); os.IsNotExist(err) {
+			return path
+		}
+	}
+}
+
+// archiveAttempts limits attempts to archive one backup during sweeping
+const archiveAttempts = 4
+
+// archiveBackoff is delay before second attempt, doubled for each next one.
+// Replaced in tests.
+var archiveBackoff = 100 * time.Millisecond
+
+// archiveBackup compresses backup aRemoval if required, archives it and
+// removes after success
+func (l *Logger) archiveBackup(aRemoval removal) error {
+	path := aRemoval.path
+	if l.isActive(path) {
+		return nil
+	}
+
+	if l.compress && !isCompressed(path) {
+		if err := l.compressBackup(path); err != nil {
+			return err
+		}
+		path += l.compression().Suffix()
+	}
+
+	size := aRemoval.size
+	backoff := archiveBackoff
+	for attempt := 1; ; attempt++ {
+		// Shifted or removed by other process
+		info, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err == nil {
+			size = info.Size()
+		}
+
+		err = l.archiver.Archive(path)
+		if err == nil {
+			break
+		}
+
+		if attempt == archiveAttempts || l.needShutdown() {
+			return errors.Wrapf(err, "can't archive %s", path)
+		}
+
+		<-time.After(backoff)
+		backoff *= 2
+	}
+
+	aRemoval.path, aRemoval.size = path, size
+	return l.removeBackup(aRemoval, true)
+}
+
+// removeBackup removes backup aRemoval unless it became active log file
+func (l *Logger) removeBackup(aRemoval removal, aArchived bool) error {
+	l.backupLock.Lock()
+	defer l.backupLock.Unlock()
+
+	if l.isActive(aRemoval.path) {
+		return nil
+	}
+
+	if err := os.Remove(aRemoval.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	removeEmptyDirs(filepath.Dir(aRemoval.path), l.backupsRoot())
+	l.queueEvent(RemoveEvent{Filename: aRemoval.path, Size: aRemoval.size, Reason: aRemoval.reason, Archived: aArchived})
+
+	return nil
+}
+
+// isCompressed checks aPath has suffix of registered compressor
+func isCompressed(aPath string) bool {
+	for _, s := range compressedSuffixes() {
+		if strings.HasSuffix(aPath, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// copyFile copies aSrc to aDst through temporary file, so aDst never seen
+// half-written
+func copyFile(aSrc, aDst string) (err error) {
+	src, err := os.Open(aSrc)
+	if err != nil {
+		return errors.Wrapf(err, "can't open %s", aSrc)
+	}
+	defer src.Close()
+
+	if err = os.MkdirAll(filepath.Dir(aDst), 0755); err != nil {
+		return errors.Wrapf(err, "can't make directory for %s", aDst)
+	}
+
+	temp := aDst + tempSuffix
+	dst, err := os.OpenFile(temp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, fileMode)
+	if err != nil {
+		return errors.Wrapf(err, "can't create %s", temp)
+	}
+
+	defer func() {
+		if dst != nil {
+			dst.Close()
+		}
+		if err != nil {
+			os.Remove(temp)
+		}
+	}()
+
+	if _, err = io.Copy(dst, src); err != nil {
+		return errors.Wrapf(err, "can't copy %s to %s", aSrc, temp)
+	}
+
+	if err = dst.Sync(); err != nil {
+		return errors.Wrapf(err, "can't sync %s", temp)
+	}
+
+	err = dst.Close()
+	dst = nil
+	if err != nil {
+		return errors.Wrapf(err, "can't close %s", temp)
+	}
+
+	return errors.Wrapf(os.Rename(temp, aDst), "can't rename %s", temp)
+}
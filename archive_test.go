@@ -0,0 +1,163 @@
+package rollinglog
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirArchiver(t *testing.T) {
+	dir := makeTempDir("TestDirArchiver", t)
+	defer os.RemoveAll(dir)
+
+	archive := filepath.Join(dir, "archive")
+	lf := logFile(dir)
+	l := New(WithLogFile(lf), WithMaxBytes(10), WithMaxBackups(1), UseCompression,
+		WithArchiver(DirArchiver(archive)))
+	defer l.Close()
+
+	b := []byte("123456789")
+	for i := 0; i < 4; i++ {
+		_, err := l.Write(b)
+		require.NoError(t, err)
+		l.wg.Wait()
+	}
+
+	// Log file, one compressed backup and archive directory left
+	count, err := getFilesInDir(t, dir)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	// Removed backups archived compressed
+	files, err := ioutil.ReadDir(archive)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(files))
+	for _, f := range files {
+		assert.True(t, strings.HasSuffix(f.Name(), compressSuffix), f.Name())
+		_, err = os.Stat(filepath.Join(dir, f.Name()))
+		assert.True(t, os.IsNotExist(err), "archived backup not removed")
+	}
+}
+
+func TestArchiverRetries(t *testing.T) {
+	dir := makeTempDir("TestArchiverRetries", t)
+	defer os.RemoveAll(dir)
+
+	archiveBackoff = time.Millisecond
+	defer func() { archiveBackoff = 100 * time.Millisecond }()
+
+	lock := sync.Mutex{}
+	calls := 0
+	failures := 2
+	archiver := ArchiverFunc(func(aPath string) error {
+		lock.Lock()
+		defer lock.Unlock()
+
+		calls++
+		if calls <= failures {
+			return fmt.Errorf("failed %d", calls)
+		}
+		return nil
+	})
+
+	errs := []string{}
+	lf := logFile(dir)
+	l := New(WithLogFile(lf), WithMaxBytes(10), WithMaxBackups(1), WithArchiver(archiver),
+		WithErrorHandler(func(err error) { errs = append(errs, err.Error()) }))
+	defer l.Close()
+
+	b := []byte("123456789")
+	for i := 0; i < 3; i++ {
+		_, err := l.Write(b)
+		require.NoError(t, err)
+		l.wg.Wait()
+	}
+
+	// Archived by third attempt
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, 0, len(errs))
+
+	count, err := getFilesInDir(t, dir)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	// Backup kept when all attempts failed
+	failures = calls + archiveAttempts
+	_, err = l.Write(b)
+	require.NoError(t, err)
+	l.wg.Wait()
+
+	require.Equal(t, 1, len(errs))
+	assert.Contains(t, errs[0], "can't archive")
+
+	count, err = getFilesInDir(t, dir)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+}
+
+func TestDirArchiverNumbered(t *testing.T) {
+	dir := makeTempDir("TestDirArchiverNumbered", t)
+	defer os.RemoveAll(dir)
+
+	archive := filepath.Join(dir, "archive")
+	lf := logFile(dir)
+	l := New(WithLogFile(lf), WithMaxBytes(10), WithMaxBackups(1), WithBackupNamer(NumberedNamer()),
+		WithArchiver(DirArchiver(archive)))
+	defer l.Close()
+
+	for i := 0; i < 4; i++ {
+		_, err := l.Write([]byte(fmt.Sprintf("%d23456789", i)))
+		require.NoError(t, err)
+		l.wg.Wait()
+	}
+
+	// Backups named foobar.2.log each time archived under unique names
+	files, err := ioutil.ReadDir(archive)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(files))
+	existsWithContent(filepath.Join(archive, "foobar.2.log"), []byte("023456789"), t)
+	existsWithContent(filepath.Join(archive, "foobar.2-1.log"), []byte("123456789"), t)
+}
+
+func TestArchiverFreeSpace(t *testing.T) {
+	dir := makeTempDir("TestArchiverFreeSpace", t)
+	defer os.RemoveAll(dir)
+	defer mockDiskFree(t, 50)()
+
+	archived := []string{}
+	archiver := ArchiverFunc(func(aPath string) error {
+		archived = append(archived, filepath.Base(aPath))
+		return nil
+	})
+
+	lf := logFile(dir)
+	l := New(WithLogFile(lf), WithMaxBytes(10), WithArchiver(archiver),
+		WithFreeSpaceWatermark(15, 25, DropWrites), WithErrorHandler(func(error) {}))
+	defer l.Close()
+
+	b := []byte("123456789")
+	for i := 0; i < 4; i++ {
+		_, err := l.Write(b)
+		require.NoError(t, err)
+		<-time.After(time.Millisecond * 2)
+	}
+
+	// Backups pruned for free space archived before removal
+	l.lastSpaceCheck = time.Time{}
+	_, err := l.Write(b)
+	require.NoError(t, err)
+	l.wg.Wait()
+
+	assert.Equal(t, 2, len(archived))
+	count, err := getFilesInDir(t, dir)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
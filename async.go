@@ -0,0 +1,179 @@
+package rollinglog
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// OverflowPolicy defines behavior of asynchronous logger when its buffer is full
+type OverflowPolicy int
+
+const (
+	// BlockOnOverflow blocks write until buffer has space
+	BlockOnOverflow OverflowPolicy = iota
+	// DropNewest drops write which doesn't fit into buffer
+	DropNewest
+	// DropOldest drops oldest buffered writes to free space for new one
+	DropOldest
+)
+
+// asyncQueue is a bounded queue of writes drained by single goroutine
+type asyncQueue struct {
+	lock     sync.Mutex
+	cond     *sync.Cond
+	records  [][]byte
+	size     int
+	limit    int
+	overflow OverflowPolicy
+	write    func([][]byte)
+
+	running bool
+	closing bool
+	busy    bool
+	done    chan struct{}
+
+	droppedWrites uint64
+	droppedBytes  uint64
+}
+
+func newAsyncQueue(aLimit int, aOverflow OverflowPolicy, aWrite func([][]byte)) *asyncQueue {
+	q := &asyncQueue{
+		limit:    aLimit,
+		overflow: aOverflow,
+		write:    aWrite,
+	}
+	q.cond = sync.NewCond(&q.lock)
+
+	return q
+}
+
+// push puts copy of aData into queue. Writer goroutine started on demand.
+// Data bigger than limit accepted when queue is empty.
+func (q *asyncQueue) push(aData []byte) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if !q.running {
+		q.running = true
+		q.done = make(chan struct{})
+		go q.run(q.done)
+	}
+
+	switch q.overflow {
+	case DropNewest:
+		if q.size > 0 && q.size+len(aData) > q.limit {
+			q.dropped(aData)
+			return
+		}
+	case DropOldest:
+		for q.size > 0 && q.size+len(aData) > q.limit {
+			q.dropped(q.records[0])
+			q.size -= len(q.records[0])
+			q.records[0] = nil
+			q.records = q.records[1:]
+		}
+	default:
+		for q.size > 0 && q.size+len(aData) > q.limit {
+			q.cond.Wait()
+		}
+	}
+
+	q.records = append(q.records, append([]byte(nil), aData...))
+	q.size += len(aData)
+	q.cond.Broadcast()
+}
+
+func (q *asyncQueue) dropped(aData []byte) {
+	atomic.AddUint64(&q.droppedWrites, 1)
+	atomic.AddUint64(&q.droppedBytes, uint64(len(aData)))
+}
+
+func (q *asyncQueue) run(aDone chan struct{}) {
+	defer close(aDone)
+
+	for {
+		q.lock.Lock()
+		for len(q.records) == 0 && !q.closing {
+			q.cond.Wait()
+		}
+
+		if len(q.records) == 0 {
+			// Closing and everything written
+			q.running = false
+			q.lock.Unlock()
+			return
+		}
+
+		batch := q.records
+		q.records = nil
+		q.size = 0
+		q.busy = true
+		q.cond.Broadcast()
+		q.lock.Unlock()
+
+		q.write(batch)
+
+		q.lock.Lock()
+		q.busy = false
+		q.cond.Broadcast()
+		q.lock.Unlock()
+	}
+}
+
+// close writes all queued data and stops writer goroutine
+func (q *asyncQueue) close() {
+	q.lock.Lock()
+	if !q.running {
+		q.lock.Unlock()
+		return
+	}
+
+	q.closing = true
+	done := q.done
+	q.cond.Broadcast()
+	q.lock.Unlock()
+
+	<-done
+
+	q.lock.Lock()
+	q.closing = false
+	q.lock.Unlock()
+}
+
+// flush waits until all queued data written
+func (q *asyncQueue) flush() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	for q.running && (len(q.records) > 0 || q.busy) {
+		q.cond.Wait()
+	}
+}
+
+// writeQueued writes batch of queued data to log file
+func (l *Logger) writeQueued(aBatch [][]byte) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	for _, p := range aBatch {
+		if _, err := l.writeData(p); err != nil {
+			l.errHandler(err)
+		}
+	}
+}
+
+// DroppedWrites returns count of writes dropped by asynchronous logger on overflow
+func (l *Logger) DroppedWrites() uint64 {
+	if l.queue == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&l.queue.droppedWrites)
+}
+
+// DroppedBytes returns count of bytes dropped by asynchronous logger on overflow
+func (l *Logger) DroppedBytes() uint64 {
+	if l.queue == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&l.queue.droppedBytes)
+}
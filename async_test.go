@@ -0,0 +1,184 @@
+package rollinglog
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockedWriter collects written batches, first batch blocked until release
+type blockedWriter struct {
+	lock    sync.Mutex
+	data    []string
+	started chan struct{}
+	release chan struct{}
+}
+
+func newBlockedWriter() *blockedWriter {
+	return &blockedWriter{started: make(chan struct{}), release: make(chan struct{})}
+}
+
+func (w *blockedWriter) write(aBatch [][]byte) {
+	if w.started != nil {
+		close(w.started)
+		w.started = nil
+		<-w.release
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	for _, b := range aBatch {
+		w.data = append(w.data, string(b))
+	}
+}
+
+func TestAsyncQueueDropNewest(t *testing.T) {
+	w := newBlockedWriter()
+	started := w.started
+	q := newAsyncQueue(6, DropNewest, w.write)
+
+	q.push([]byte("first"))
+	<-started
+
+	q.push([]byte("123"))
+	q.push([]byte("456"))
+	q.push([]byte("789"))
+	q.push([]byte("0"))
+
+	assert.Equal(t, uint64(2), q.droppedWrites)
+	assert.Equal(t, uint64(4), q.droppedBytes)
+
+	close(w.release)
+	q.close()
+
+	assert.Equal(t, []string{"first", "123", "456"}, w.data)
+}
+
+func TestAsyncQueueDropOldest(t *testing.T) {
+	w := newBlockedWriter()
+	started := w.started
+	q := newAsyncQueue(6, DropOldest, w.write)
+
+	q.push([]byte("first"))
+	<-started
+
+	q.push([]byte("123"))
+	q.push([]byte("456"))
+	q.push([]byte("789"))
+	q.push([]byte("0123456789"))
+
+	assert.Equal(t, uint64(3), q.droppedWrites)
+	assert.Equal(t, uint64(9), q.droppedBytes)
+
+	close(w.release)
+	q.close()
+
+	assert.Equal(t, []string{"first", "0123456789"}, w.data)
+}
+
+func TestAsyncQueueBlock(t *testing.T) {
+	w := newBlockedWriter()
+	started := w.started
+	q := newAsyncQueue(6, BlockOnOverflow, w.write)
+
+	q.push([]byte("first"))
+	<-started
+
+	q.push([]byte("123"))
+	q.push([]byte("456"))
+
+	pushed := make(chan struct{})
+	go func() {
+		q.push([]byte("789"))
+		close(pushed)
+	}()
+
+	select {
+	case <-pushed:
+		t.Fatal("push not blocked")
+	default:
+	}
+
+	close(w.release)
+	<-pushed
+	q.close()
+
+	assert.Equal(t, []string{"first", "123", "456", "789"}, w.data)
+	assert.Equal(t, uint64(0), q.droppedWrites)
+}
+
+func TestAsyncWrite(t *testing.T) {
+	dir := makeTempDir("TestAsyncWrite", t)
+	defer os.RemoveAll(dir)
+
+	lf := logFile(dir)
+	l := New(WithLogFile(lf), WithAsyncBuffer(1024))
+
+	b := []byte("123456789")
+	expected := &bytes.Buffer{}
+
+	for i := 0; i < 100; i++ {
+		n, err := l.Write(b)
+		require.NoError(t, err)
+		assert.Equal(t, len(b), n)
+		expected.Write(b)
+	}
+
+	require.NoError(t, l.Close())
+	existsWithContent(lf, expected.Bytes(), t)
+	assert.Equal(t, uint64(0), l.DroppedWrites())
+	assert.Equal(t, uint64(0), l.DroppedBytes())
+
+	// Restarted after close
+	_, err := l.Write(b)
+	require.NoError(t, err)
+	require.NoError(t, l.Close())
+	existsWithContent(lf, append(expected.Bytes(), b...), t)
+}
+
+func TestAsyncRotateAndReopen(t *testing.T) {
+	dir := makeTempDir("TestAsyncRotateAndReopen", t)
+	defer os.RemoveAll(dir)
+
+	lf := logFile(dir)
+	l := New(WithLogFile(lf), WithAsyncBuffer(1<<20))
+	defer l.Close()
+
+	b := []byte("123456\n")
+	expected := &bytes.Buffer{}
+	for i := 0; i < 1000; i++ {
+		_, err := l.Write(b)
+		require.NoError(t, err)
+		expected.Write(b)
+	}
+
+	// Queued data written before rotation
+	require.NoError(t, l.Rotate())
+
+	backups, err := l.backups()
+	require.NoError(t, err)
+	require.Equal(t, 1, len(backups))
+	existsWithContent(filepath.Join(dir, backups[0].name), expected.Bytes(), t)
+	existsWithContent(lf, []byte{}, t)
+
+	// Queued data written into moved file before reopen
+	moved := lf + ".moved"
+	_, err = l.Write(b)
+	require.NoError(t, err)
+	require.NoError(t, l.Sync())
+	require.NoError(t, os.Rename(lf, moved))
+
+	for i := 0; i < 1000; i++ {
+		_, err = l.Write(b)
+		require.NoError(t, err)
+	}
+	require.NoError(t, l.Reopen())
+
+	existsWithContent(moved, bytes.Repeat(b, 1001), t)
+	existsWithContent(lf, []byte{}, t)
+}
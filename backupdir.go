@@ -0,0 +1,101 @@
+package rollinglog
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// backupsRoot returns directory where backups stored. Relative backup
+// directory is resolved against directory of log file.
+func (l *Logger) backupsRoot() string {
+	logDir := filepath.Dir(l.logFilename())
+
+	switch {
+	case l.backupDir == "":
+		return logDir
+	case filepath.IsAbs(l.backupDir):
+		return l.backupDir
+	}
+	return filepath.Join(logDir, l.backupDir)
+}
+
+// backupDirFor returns directory for backup rotated at aTime
+func (l *Logger) backupDirFor(aTime time.Time) string {
+	if l.partitions == "" {
+		return l.backupsRoot()
+	}
+	return filepath.Join(l.backupsRoot(), filepath.FromSlash(aTime.Format(l.partitions)))
+}
+
+// partitionDepth returns count of directory levels between backups root
+// and backups
+func (l *Logger) partitionDepth() int {
+	if l.partitions == "" {
+		return 0
+	}
+	return len(strings.Split(strings.Trim(filepath.ToSlash(l.partitions), "/"), "/"))
+}
+
+// backups returns backups of log file sorted by timestamp. Backups directory
+// created by first rotation, so there are no backups without it.
+func (l *Logger) backups() ([]backupInfo, error) {
+	backups, err := filterBackups(l.backupsRoot(), filepath.Base(l.logFilename()), l.partitionDepth(),
+		l.listingNamer(), l.compression().Suffix())
+	if os.IsNotExist(errors.Cause(err)) {
+		return nil, nil
+	}
+	return backups, err
+}
+
+// backupFile is a file found in backups directory
+type backupFile struct {
+	// path relative to backups root
+	path string
+	info os.FileInfo
+}
+
+// listFiles returns files placed in subdirectories of aDir at aDepth level
+func listFiles(aDir string, aDepth int) ([]backupFile, error) {
+	files, err := ioutil.ReadDir(aDir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't read backups directory: %s", aDir)
+	}
+
+	result := []backupFile{}
+	for _, f := range files {
+		switch {
+		case aDepth == 0 && !f.IsDir():
+			result = append(result, backupFile{f.Name(), f})
+		case aDepth > 0 && f.IsDir():
+			sub, err := listFiles(filepath.Join(aDir, f.Name()), aDepth-1)
+			if err != nil {
+				return nil, err
+			}
+			for _, s := range sub {
+				result = append(result, backupFile{filepath.Join(f.Name(), s.path), s.info})
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// removeEmptyDirs removes empty directories from aDir up to aRoot (excluding)
+func removeEmptyDirs(aDir, aRoot string) {
+	rel, err := filepath.Rel(aRoot, aDir)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return
+	}
+
+	for ; rel != "."; rel = filepath.Dir(rel) {
+		// Fails when directory is not empty
+		if err := os.Remove(filepath.Join(aRoot, rel)); err != nil {
+			return
+		}
+	}
+}
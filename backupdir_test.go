@@ -0,0 +1,153 @@
+package rollinglog
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackupsRoot(t *testing.T) {
+	lf := filepath.Join("logs", "app.log")
+
+	l := New(WithLogFile(lf))
+	assert.Equal(t, "logs", l.backupsRoot())
+	assert.Equal(t, 0, l.partitionDepth())
+
+	l = New(WithLogFile(lf), WithBackupDir("archive"))
+	assert.Equal(t, filepath.Join("logs", "archive"), l.backupsRoot())
+
+	abs := filepath.Join(os.TempDir(), "archive")
+	l = New(WithLogFile(lf), WithBackupDir(abs), WithBackupPartitions("2006/01/02"))
+	assert.Equal(t, abs, l.backupsRoot())
+	assert.Equal(t, 3, l.partitionDepth())
+
+	ts := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	assert.Equal(t, filepath.Join(abs, "2026", "10", "16"), l.backupDirFor(ts))
+}
+
+func TestBackupDir(t *testing.T) {
+	dir := makeTempDir("TestBackupDir", t)
+	defer os.RemoveAll(dir)
+
+	lf := logFile(dir)
+	l := New(WithLogFile(lf), WithMaxBytes(10), WithMaxBackups(2), WithBackupDir("archive"))
+
+	for i := 1; i <= 4; i++ {
+		_, err := l.Write([]byte(fmt.Sprintf("%d23456789", i)))
+		require.NoError(t, err)
+	}
+
+	// Wait for sweeping
+	<-time.After(time.Millisecond * 10)
+	require.NoError(t, l.Close())
+
+	count, err := getFilesInDir(t, dir)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count, "log file and archive expected")
+
+	archive := filepath.Join(dir, "archive")
+	backups, err := filterBackups(archive, filepath.Base(lf), 0, defaultNamer)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(backups))
+
+	existsWithContent(filepath.Join(archive, backups[0].name), []byte("323456789"), t)
+	existsWithContent(filepath.Join(archive, backups[1].name), []byte("223456789"), t)
+}
+
+func TestBackupDirBeforeRotation(t *testing.T) {
+	dir := makeTempDir("TestBackupDirBeforeRotation", t)
+	defer os.RemoveAll(dir)
+
+	errs := []string{}
+	lock := sync.Mutex{}
+	l := New(WithLogFile(logFile(dir)), WithBackupDir("archive"), WithMaxAgeDuration(time.Hour),
+		WithSweepInterval(5*time.Millisecond), WithErrorHandler(func(err error) {
+			lock.Lock()
+			defer lock.Unlock()
+			errs = append(errs, err.Error())
+		}))
+
+	_, err := l.Write([]byte("123456789"))
+	require.NoError(t, err)
+
+	// Sweeping on open and by interval
+	<-time.After(time.Millisecond * 30)
+	require.NoError(t, l.Close())
+
+	backups, err := l.backups()
+	require.NoError(t, err)
+	assert.Empty(t, backups)
+
+	lock.Lock()
+	defer lock.Unlock()
+	assert.Empty(t, errs)
+}
+
+func TestBackupPartitions(t *testing.T) {
+	dir := makeTempDir("TestBackupPartitions", t)
+	defer os.RemoveAll(dir)
+
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	currentTime = func() time.Time { return now }
+	defer func() { currentTime = time.Now }()
+
+	lf := logFile(dir)
+	archive := filepath.Join(dir, "archive")
+	l := New(WithLogFile(lf), WithMaxBytes(10), WithMaxBackups(2), UseCompression,
+		WithBackupDir("archive"), WithBackupPartitions("2006/01/02"))
+
+	// Leftover of crash in partition
+	leftover := filepath.Join(archive, "2026", "10", "13", "foobar.20261013120000.000.log.gz.tmp")
+	require.NoError(t, os.MkdirAll(filepath.Dir(leftover), 0755))
+	require.NoError(t, ioutil.WriteFile(leftover, []byte("0"), 0644))
+
+	for i := 1; i <= 4; i++ {
+		_, err := l.Write([]byte(fmt.Sprintf("%d23456789", i)))
+		require.NoError(t, err)
+
+		// Wait for sweeping
+		<-time.After(time.Millisecond * 10)
+		now = now.Add(24 * time.Hour)
+	}
+	require.NoError(t, l.Close())
+
+	_, err := os.Stat(filepath.Join(archive, "2026", "10", "13"))
+	assert.True(t, os.IsNotExist(err), "leftover partition not removed")
+	_, err = os.Stat(filepath.Join(archive, "2026", "10", "15"))
+	assert.True(t, os.IsNotExist(err), "expired partition not removed")
+
+	backups, err := l.backups()
+	require.NoError(t, err)
+	require.Equal(t, 2, len(backups))
+
+	assert.Equal(t, filepath.Join("2026", "10", "17", "foobar.20261017120000.000.log.gz"), backups[0].name)
+	assert.Equal(t, filepath.Join("2026", "10", "16", "foobar.20261016120000.000.log.gz"), backups[1].name)
+}
+
+func TestRemoveEmptyDirs(t *testing.T) {
+	dir := makeTempDir("TestRemoveEmptyDirs", t)
+	defer os.RemoveAll(dir)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "2026", "10", "16"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "2026", "11"), 0755))
+
+	removeEmptyDirs(filepath.Join(dir, "2026", "10", "16"), dir)
+
+	_, err := os.Stat(filepath.Join(dir, "2026", "10"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(dir, "2026", "11"))
+	assert.NoError(t, err)
+
+	// Root and directories outside of root are kept
+	removeEmptyDirs(dir, dir)
+	removeEmptyDirs(filepath.Join(dir, ".."), dir)
+	_, err = os.Stat(dir)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,142 @@
+package rollinglog
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// SyncPolicy defines when written data synced to disk. Log file synced on close
+// with any policy.
+type SyncPolicy struct {
+	everyWrite bool
+	bytes      uint64
+	interval   time.Duration
+}
+
+// SyncNever never syncs log file except on close
+func SyncNever() SyncPolicy {
+	return SyncPolicy{}
+}
+
+// SyncEveryWrite syncs log file after each write
+func SyncEveryWrite() SyncPolicy {
+	return SyncPolicy{everyWrite: true}
+}
+
+// SyncEveryBytes syncs log file when aBytes written since last sync
+func SyncEveryBytes(aBytes uint64) SyncPolicy {
+	return SyncPolicy{bytes: aBytes}
+}
+
+// SyncEveryInterval syncs written data not later than aInterval after write
+func SyncEveryInterval(aInterval time.Duration) SyncPolicy {
+	return SyncPolicy{interval: aInterval}
+}
+
+// writeFile writes aData to opened log file and syncs it according to policy
+func (l *Logger) writeFile(p []byte) (n int, err error) {
+	if l.buf != nil {
+		n, err = l.buf.Write(p)
+	} else {
+		n, err = l.file.Write(p)
+	}
+
+	l.unsynced += uint64(n)
+	if err != nil {
+		return n, err
+	}
+
+	return n, l.syncIfRequired()
+}
+
+func (l *Logger) syncIfRequired() error {
+	if l.unsynced == 0 {
+		return nil
+	}
+
+	switch {
+	case l.syncPolicy.everyWrite:
+		return l.syncFile()
+	case l.syncPolicy.bytes > 0:
+		if l.unsynced >= l.syncPolicy.bytes {
+			return l.syncFile()
+		}
+	case l.syncPolicy.interval > 0:
+		if l.syncTimer == nil {
+			l.syncTimer = time.AfterFunc(l.syncPolicy.interval, l.onSyncTimer)
+		}
+	}
+
+	return nil
+}
+
+// flushFile writes buffered data to log file
+func (l *Logger) flushFile() error {
+	if l.buf == nil {
+		return nil
+	}
+
+	if err := l.buf.Flush(); err != nil {
+		return errors.Wrapf(err, "can't flush %s", l.filename)
+	}
+	return nil
+}
+
+// syncFile writes buffered data and commits log file to disk
+func (l *Logger) syncFile() error {
+	if l.file == nil {
+		return nil
+	}
+
+	if err := l.flushFile(); err != nil {
+		return err
+	}
+
+	l.unsynced = 0
+	if err := l.file.Sync(); err != nil {
+		return errors.Wrapf(err, "can't sync %s", l.filename)
+	}
+	return nil
+}
+
+func (l *Logger) stopSyncTimer() {
+	if l.syncTimer != nil {
+		l.syncTimer.Stop()
+		l.syncTimer = nil
+	}
+}
+
+func (l *Logger) onSyncTimer() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.syncTimer = nil
+	if err := l.syncFile(); err != nil {
+		l.errHandler(err)
+	}
+}
+
+// Flush writes data buffered by logger to log file
+func (l *Logger) Flush() error {
+	if l.queue != nil {
+		l.queue.flush()
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return l.flushFile()
+}
+
+// Sync writes data buffered by logger to log file and commits it to disk
+func (l *Logger) Sync() error {
+	if l.queue != nil {
+		l.queue.flush()
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return l.syncFile()
+}
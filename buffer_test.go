@@ -0,0 +1,126 @@
+package rollinglog
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteBuffer(t *testing.T) {
+	dir := makeTempDir("TestWriteBuffer", t)
+	defer os.RemoveAll(dir)
+
+	lf := logFile(dir)
+	l := New(WithLogFile(lf), WithWriteBuffer(1024), WithMaxBytes(20))
+
+	b := []byte("123456789")
+	n, err := l.Write(b)
+	require.NoError(t, err)
+	assert.Equal(t, len(b), n)
+	existsWithContent(lf, []byte{}, t)
+
+	require.NoError(t, l.Flush())
+	existsWithContent(lf, b, t)
+
+	_, err = l.Write(b)
+	require.NoError(t, err)
+	require.NoError(t, l.Sync())
+	existsWithContent(lf, append(b, b...), t)
+
+	// Buffer written on rotation
+	_, err = l.Write(b)
+	require.NoError(t, err)
+	existsWithContent(lf, []byte{}, t)
+	assert.Equal(t, []string{string(b) + string(b), ""}, readBackups(t, dir, lf))
+
+	// and on close
+	require.NoError(t, l.Close())
+	existsWithContent(lf, b, t)
+}
+
+func TestSyncEveryBytes(t *testing.T) {
+	dir := makeTempDir("TestSyncEveryBytes", t)
+	defer os.RemoveAll(dir)
+
+	lf := logFile(dir)
+	l := New(WithLogFile(lf), WithWriteBuffer(1024), WithSyncPolicy(SyncEveryBytes(15)))
+	defer l.Close()
+
+	b := []byte("123456789")
+	_, err := l.Write(b)
+	require.NoError(t, err)
+	existsWithContent(lf, []byte{}, t)
+
+	_, err = l.Write(b)
+	require.NoError(t, err)
+	existsWithContent(lf, append(b, b...), t)
+	assert.Equal(t, uint64(0), l.unsynced)
+}
+
+func TestSyncEveryWrite(t *testing.T) {
+	dir := makeTempDir("TestSyncEveryWrite", t)
+	defer os.RemoveAll(dir)
+
+	lf := logFile(dir)
+	l := New(WithLogFile(lf), WithWriteBuffer(1024), WithSyncPolicy(SyncEveryWrite()))
+	defer l.Close()
+
+	b := []byte("123456789")
+	_, err := l.Write(b)
+	require.NoError(t, err)
+	existsWithContent(lf, b, t)
+	assert.Equal(t, uint64(0), l.unsynced)
+}
+
+func TestSyncEveryInterval(t *testing.T) {
+	dir := makeTempDir("TestSyncEveryInterval", t)
+	defer os.RemoveAll(dir)
+
+	lf := logFile(dir)
+	l := New(WithLogFile(lf), WithWriteBuffer(1024), WithSyncPolicy(SyncEveryInterval(20*time.Millisecond)))
+	defer l.Close()
+
+	b := []byte("123456789")
+	_, err := l.Write(b)
+	require.NoError(t, err)
+	existsWithContent(lf, []byte{}, t)
+
+	assert.Eventually(t, func() bool {
+		info, err := os.Stat(lf)
+		return err == nil && info.Size() == int64(len(b))
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestSyncNever(t *testing.T) {
+	dir := makeTempDir("TestSyncNever", t)
+	defer os.RemoveAll(dir)
+
+	lf := logFile(dir)
+	l := New(WithLogFile(lf), WithSyncPolicy(SyncNever()))
+	defer l.Close()
+
+	b := []byte("123456789")
+	_, err := l.Write(b)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(len(b)), l.unsynced)
+	assert.Nil(t, l.syncTimer)
+}
+
+func TestFlushAsync(t *testing.T) {
+	dir := makeTempDir("TestFlushAsync", t)
+	defer os.RemoveAll(dir)
+
+	lf := logFile(dir)
+	l := New(WithLogFile(lf), WithAsyncBuffer(1024), WithWriteBuffer(1024))
+	defer l.Close()
+
+	b := []byte("123456789")
+	_, err := l.Write(b)
+	require.NoError(t, err)
+
+	require.NoError(t, l.Flush())
+	existsWithContent(lf, b, t)
+}
@@ -3,25 +3,136 @@ package rollinglog
 import (
 	"compress/gzip"
 	"io"
+	"io/ioutil"
 	"os"
+	"sort"
+	"sync"
 
 	"github.com/hashicorp/go-multierror"
 	"github.com/pkg/errors"
 )
 
+// Compressor compresses backups
+type Compressor interface {
+	// Suffix returns suffix added to name of compressed backup (e.g. ".gz")
+	Suffix() string
+	// Compress writes compressed data from src to dst
+	Compress(dst io.Writer, src io.Reader) error
+}
+
+type gzipCompressor struct {
+	level int
+}
+
+// GzipCompressor returns gzip compressor with compression aLevel
+// (e.g. gzip.DefaultCompression or gzip.BestSpeed)
+func GzipCompressor(aLevel int) Compressor {
+	return gzipCompressor{level: aLevel}
+}
+
+func (g gzipCompressor) Suffix() string {
+	return compressSuffix
+}
+
+func (g gzipCompressor) Compress(dst io.Writer, src io.Reader) error {
+	gz, err := gzip.NewWriterLevel(dst, g.level)
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(gz, src); err != nil {
+		return err
+	}
+
+	return gz.Close()
+}
+
+// tempSuffix added to name of file while it compressed
+const tempSuffix = ".tmp"
+
+// Verifier is implemented by compressors able to check integrity of
+// compressed data
+type Verifier interface {
+	Verify(src io.Reader) error
+}
+
+func (g gzipCompressor) Verify(src io.Reader) error {
+	gz, err := gzip.NewReader(src)
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(ioutil.Discard, gz); err != nil {
+		return err
+	}
+
+	return gz.Close()
+}
+
+var (
+	compressorsLock sync.RWMutex
+	compressors     = map[string]Compressor{
+		compressSuffix: GzipCompressor(gzip.DefaultCompression),
+	}
+)
+
+// RegisterCompressor registers aCompressor, so backups with its suffix
+// recognised as compressed ones by any logger
+func RegisterCompressor(aCompressor Compressor) {
+	compressorsLock.Lock()
+	defer compressorsLock.Unlock()
+
+	compressors[aCompressor.Suffix()] = aCompressor
+}
+
+// registeredCompressor returns compressor registered for aSuffix
+func registeredCompressor(aSuffix string) Compressor {
+	compressorsLock.RLock()
+	defer compressorsLock.RUnlock()
+
+	return compressors[aSuffix]
+}
+
+// compressedSuffixes returns suffixes of all registered compressors and aExtra
+func compressedSuffixes(aExtra ...string) []string {
+	compressorsLock.RLock()
+	defer compressorsLock.RUnlock()
+
+	unique := map[string]bool{}
+	for s := range compressors {
+		unique[s] = true
+	}
+	for _, s := range aExtra {
+		unique[s] = true
+	}
+
+	result := make([]string, 0, len(unique))
+	for s := range unique {
+		result = append(result, s)
+	}
+	sort.Strings(result)
+
+	return result
+}
+
+// compressor compresses backup file with Compressor
 type compressor struct {
 	destFile      string
+	tempFile      string
 	sourceFile    string
+	codec         Compressor
 	errors        *multierror.Error
 	src           *os.File
 	dst           *os.File
 	fileForRemove string
 }
 
-func newCompressor(aSource string) *compressor {
+func newCompressor(aSource string, aCodec Compressor) *compressor {
 	return &compressor{
 		sourceFile: aSource,
-		destFile:   aSource + compressSuffix,
+		destFile:   aSource + aCodec.Suffix(),
+		tempFile:   aSource + aCodec.Suffix() + tempSuffix,
+		codec:      aCodec,
 		errors:     new(multierror.Error),
 	}
 }
@@ -32,22 +143,33 @@ func (c *compressor) Compress() (err error) {
 		return c.finish()
 	}
 
-	if c.dst, err = os.OpenFile(c.destFile, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, fileMode); err != nil {
+	// Compress into temporary file, so compressed log never seen half-written
+	if c.dst, err = os.OpenFile(c.tempFile, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, fileMode); err != nil {
 		c.errors = multierror.Append(c.errors, errors.Wrap(err, "Failed to create compressed log"))
 		return c.finish()
 	}
 
-	gz := gzip.NewWriter(c.dst)
+	c.fileForRemove = c.tempFile
 
-	if _, err = io.Copy(gz, c.src); err != nil {
-		c.fileForRemove = c.destFile
+	if err = c.codec.Compress(c.dst, c.src); err != nil {
 		c.errors = multierror.Append(c.errors, errors.Wrap(err, "Failed to write compressed log file"))
 		return c.finish()
 	}
 
-	if err = gz.Close(); err != nil {
-		c.fileForRemove = c.destFile
-		c.errors = multierror.Append(errors.Wrapf(err, "Failed to close gz writer for %s", c.destFile))
+	if err = c.dst.Sync(); err != nil {
+		c.errors = multierror.Append(c.errors, errors.Wrapf(err, "Failed to sync %s", c.tempFile))
+		return c.finish()
+	}
+
+	err = c.dst.Close()
+	c.dst = nil
+	if err != nil {
+		c.errors = multierror.Append(c.errors, errors.Wrapf(err, "Failed to close %s", c.tempFile))
+		return c.finish()
+	}
+
+	if err = os.Rename(c.tempFile, c.destFile); err != nil {
+		c.errors = multierror.Append(c.errors, errors.Wrapf(err, "Failed to rename %s", c.tempFile))
 		return c.finish()
 	}
 
@@ -64,7 +186,7 @@ func (c *compressor) finish() error {
 
 	if c.dst != nil {
 		if e := c.dst.Close(); e != nil {
-			c.errors = multierror.Append(errors.Wrapf(e, "Failed to close %s", c.destFile))
+			c.errors = multierror.Append(errors.Wrapf(e, "Failed to close %s", c.tempFile))
 		}
 	}
 
@@ -3,9 +3,11 @@ package rollinglog
 import (
 	"bytes"
 	"compress/gzip"
+	"errors"
 	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -22,7 +24,7 @@ func TestCompress(t *testing.T) {
 
 	require.NoError(t, ioutil.WriteFile(lf, data, 0644))
 
-	c := newCompressor(lf)
+	c := newCompressor(lf, GzipCompressor(gzip.DefaultCompression))
 
 	assert.True(t, strings.HasPrefix(c.destFile, c.sourceFile))
 	assert.True(t, strings.HasSuffix(c.destFile, compressSuffix))
@@ -42,6 +44,9 @@ func TestCompress(t *testing.T) {
 	require.NoError(t, err)
 	require.NoError(t, gz.Close())
 	assert.Equal(t, int64(len(w.Bytes())), info.Size())
+
+	_, err = os.Stat(c.tempFile)
+	assert.True(t, os.IsNotExist(err), "temporary file not removed")
 }
 
 func TestCompressNotExisting(t *testing.T) {
@@ -50,9 +55,181 @@ func TestCompressNotExisting(t *testing.T) {
 
 	lf := logFile(dir)
 
-	c := newCompressor(lf)
+	c := newCompressor(lf, GzipCompressor(gzip.DefaultCompression))
 	require.Error(t, c.Compress())
 
 	_, err := os.Stat(c.destFile)
 	assert.True(t, err != nil && os.IsNotExist(err), "dest created")
 }
+
+// rawCompressor copies data as is
+type rawCompressor struct{}
+
+func (rawCompressor) Suffix() string {
+	return ".raw"
+}
+
+func (rawCompressor) Compress(dst io.Writer, src io.Reader) error {
+	_, err := io.Copy(dst, src)
+	return err
+}
+
+func TestGzipCompressorLevel(t *testing.T) {
+	data := bytes.Repeat([]byte("somedata"), 100)
+
+	best := &bytes.Buffer{}
+	require.NoError(t, GzipCompressor(gzip.BestCompression).Compress(best, bytes.NewReader(data)))
+
+	none := &bytes.Buffer{}
+	require.NoError(t, GzipCompressor(gzip.NoCompression).Compress(none, bytes.NewReader(data)))
+
+	assert.Less(t, best.Len(), none.Len())
+
+	gz, err := gzip.NewReader(best)
+	require.NoError(t, err)
+	got, err := ioutil.ReadAll(gz)
+	require.NoError(t, err)
+	assert.Equal(t, data, got)
+
+	assert.Error(t, GzipCompressor(100).Compress(&bytes.Buffer{}, bytes.NewReader(data)))
+}
+
+func TestCustomCompressor(t *testing.T) {
+	dir := makeTempDir("TestCustomCompressor", t)
+	defer os.RemoveAll(dir)
+
+	lf := logFile(dir)
+	data := []byte("somedata")
+	require.NoError(t, ioutil.WriteFile(lf, data, 0644))
+
+	c := newCompressor(lf, rawCompressor{})
+	assert.Equal(t, lf+".raw", c.destFile)
+	require.NoError(t, c.Compress())
+	existsWithContent(lf+".raw", data, t)
+}
+
+func TestRegisterCompressor(t *testing.T) {
+	assert.Equal(t, []string{".gz"}, compressedSuffixes())
+	assert.Equal(t, []string{".gz", ".zst"}, compressedSuffixes(".zst", ".gz"))
+
+	RegisterCompressor(rawCompressor{})
+	defer func() {
+		compressorsLock.Lock()
+		delete(compressors, ".raw")
+		compressorsLock.Unlock()
+	}()
+
+	assert.Equal(t, []string{".gz", ".raw"}, compressedSuffixes())
+}
+
+func TestFilterMixedBackups(t *testing.T) {
+	dir := makeTempDir("TestFilterMixedBackups", t)
+	defer os.RemoveAll(dir)
+
+	lf := filepath.Join(dir, "foo.log")
+
+	files := []string{
+		"foo.20140504144133.555.log",
+		"foo.20140504144233.556.log.gz",
+		"foo.20140504144333.555.log.raw",
+		"foo.20140504144433.556.log.zst",
+		"foo.20140504144533.556.log.xz",
+	}
+
+	for _, f := range files {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, f), []byte(f), 0644))
+	}
+
+	lst, err := filterBackups(dir, filepath.Base(lf), 0, defaultNamer, ".raw", ".zst")
+	require.NoError(t, err)
+	require.Equal(t, 4, len(lst))
+
+	assert.Equal(t, "foo.20140504144433.556.log.zst", lst[0].name)
+	assert.True(t, lst[0].compressed)
+	assert.True(t, lst[1].compressed)
+	assert.True(t, lst[2].compressed)
+	assert.False(t, lst[3].compressed)
+
+	l := New(WithLogFile(lf), WithCompressor(rawCompressor{}), WithMaxBackups(2))
+	forRemove, forCompress, err := l.collectFilesForSweep()
+	require.NoError(t, err)
+	require.Equal(t, 1, len(forRemove))
+	assert.Equal(t, filepath.Join(dir, "foo.20140504144133.555.log"), forRemove[0].path)
+	assert.Equal(t, RemoveCount, forRemove[0].reason)
+	assert.Empty(t, forCompress)
+}
+
+// failedCompressor writes some data and fails
+type failedCompressor struct{}
+
+func (failedCompressor) Suffix() string {
+	return ".gz"
+}
+
+func (failedCompressor) Compress(dst io.Writer, src io.Reader) error {
+	if _, err := dst.Write([]byte("partial")); err != nil {
+		return err
+	}
+	return errors.New("failed")
+}
+
+func TestCompressFailed(t *testing.T) {
+	dir := makeTempDir("TestCompressFailed", t)
+	defer os.RemoveAll(dir)
+
+	lf := logFile(dir)
+	data := []byte("somedata")
+	require.NoError(t, ioutil.WriteFile(lf, data, 0644))
+
+	c := newCompressor(lf, failedCompressor{})
+	require.Error(t, c.Compress())
+
+	existsWithContent(lf, data, t)
+
+	count, err := getFilesInDir(t, dir)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestSweepRecovery(t *testing.T) {
+	dir := makeTempDir("TestSweepRecovery", t)
+	defer os.RemoveAll(dir)
+
+	lf := logFile(dir)
+	prefix, suffix := splitFilename(lf)
+	orphan := filepath.Join(dir, prefix+"20140504144433.555"+suffix+compressSuffix+tempSuffix)
+	require.NoError(t, ioutil.WriteFile(orphan, []byte("partial"), 0644))
+
+	l := New(WithLogFile(lf), WithMaxBytes(10), UseCompression)
+
+	b := []byte("123456789")
+	for i := 0; i < 2; i++ {
+		_, err := l.Write(b)
+		require.NoError(t, err)
+	}
+
+	l.wg.Wait()
+	require.NoError(t, l.Close())
+
+	_, err := os.Stat(orphan)
+	assert.True(t, os.IsNotExist(err), "orphan not removed")
+
+	count, err := gzFileCount(t, dir)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestGzipVerify(t *testing.T) {
+	data := bytes.Repeat([]byte("somedata"), 100)
+
+	buf := &bytes.Buffer{}
+	c := GzipCompressor(gzip.DefaultCompression)
+	require.NoError(t, c.Compress(buf, bytes.NewReader(data)))
+
+	v, ok := c.(Verifier)
+	require.True(t, ok)
+
+	assert.NoError(t, v.Verify(bytes.NewReader(buf.Bytes())))
+	assert.Error(t, v.Verify(bytes.NewReader(buf.Bytes()[:buf.Len()/2])))
+	assert.Error(t, v.Verify(bytes.NewReader(data)))
+}
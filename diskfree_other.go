@@ -0,0 +1,10 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !windows
+// +build !darwin,!dragonfly,!freebsd,!linux,!windows
+
+package rollinglog
+
+import "github.com/pkg/errors"
+
+func diskFreeSpace(aPath string) (uint64, error) {
+	return 0, errors.New("free space check is not supported on this platform")
+}
@@ -0,0 +1,17 @@
+//go:build darwin || dragonfly || freebsd || linux
+// +build darwin dragonfly freebsd linux
+
+package rollinglog
+
+import "syscall"
+
+// diskFreeSpace returns count of bytes available to unprivileged user on file
+// system of aPath
+func diskFreeSpace(aPath string) (uint64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(aPath, &st); err != nil {
+		return 0, err
+	}
+
+	return uint64(st.Bavail) * uint64(st.Bsize), nil
+}
@@ -0,0 +1,27 @@
+//go:build windows
+// +build windows
+
+package rollinglog
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var getDiskFreeSpaceEx = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+
+// diskFreeSpace returns count of bytes available to user on volume of aPath
+func diskFreeSpace(aPath string) (uint64, error) {
+	path, err := syscall.UTF16PtrFromString(aPath)
+	if err != nil {
+		return 0, err
+	}
+
+	var free uint64
+	r, _, err := getDiskFreeSpaceEx.Call(uintptr(unsafe.Pointer(path)), uintptr(unsafe.Pointer(&free)), 0, 0)
+	if r == 0 {
+		return 0, err
+	}
+
+	return free, nil
+}
@@ -0,0 +1,20 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package rollinglog
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+const processLockSupported = false
+
+func lockFile(aFile *os.File) error {
+	return errors.New("process lock is not supported on this platform")
+}
+
+func unlockFile(aFile *os.File) error {
+	return nil
+}
@@ -0,0 +1,19 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package rollinglog
+
+import (
+	"os"
+	"syscall"
+)
+
+const processLockSupported = true
+
+func lockFile(aFile *os.File) error {
+	return syscall.Flock(int(aFile.Fd()), syscall.LOCK_EX)
+}
+
+func unlockFile(aFile *os.File) error {
+	return syscall.Flock(int(aFile.Fd()), syscall.LOCK_UN)
+}
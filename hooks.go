@@ -0,0 +1,168 @@
+package rollinglog
+
+import "time"
+
+// Hooks are callbacks on log file lifecycle events, e.g. for metrics or audit.
+// Nil hooks are skipped. Hooks called synchronously: OnOpen and OnRotate
+// holding logger lock, so they must not call logger; OnCompress and OnRemove
+// from sweeping goroutine without locks held, so they may write to logger.
+type Hooks struct {
+	OnOpen     func(OpenEvent)
+	OnRotate   func(RotateEvent)
+	OnCompress func(CompressEvent)
+	OnRemove   func(RemoveEvent)
+}
+
+// OpenEvent describes opened log file
+type OpenEvent struct {
+	// Filename of log file
+	Filename string
+	// Size of existing log file in bytes
+	Size uint64
+	// Created is true for new log file
+	Created bool
+}
+
+// RotateReason is a reason of log file rotation
+type RotateReason int
+
+const (
+	// RotateSize is rotation by size limit
+	RotateSize RotateReason = iota
+	// RotateLines is rotation by lines limit
+	RotateLines
+	// RotateTime is time based rotation or switch of time pattern file name
+	RotateTime
+	// RotatePolicy is rotation required by rotation policy
+	RotatePolicy
+	// RotateForced is rotation by Rotate call
+	RotateForced
+)
+
+var rotateReasons = map[RotateReason]string{
+	RotateSize:   "size",
+	RotateLines:  "lines",
+	RotateTime:   "time",
+	RotatePolicy: "policy",
+	RotateForced: "forced",
+}
+
+func (r RotateReason) String() string {
+	return rotateReasons[r]
+}
+
+// RotateEvent describes rotated log file
+type RotateEvent struct {
+	// Filename of log file
+	Filename string
+	// Backup is path of backup log file renamed to
+	Backup string
+	// Reason of rotation
+	Reason RotateReason
+	// State of log file before rotation
+	State FileState
+	// Duration of renaming to backup
+	Duration time.Duration
+}
+
+// CompressEvent describes compressed backup
+type CompressEvent struct {
+	// Source is path of uncompressed backup, removed after compression
+	Source string
+	// Destination is path of compressed backup
+	Destination string
+	// Size of uncompressed backup in bytes
+	Size int64
+	// CompressedSize is size of compressed backup in bytes
+	CompressedSize int64
+	// Duration of compression
+	Duration time.Duration
+}
+
+// RemoveReason is a reason of backup removal
+type RemoveReason int
+
+const (
+	// RemoveAge is removal of backup older than max age
+	RemoveAge RemoveReason = iota
+	// RemoveCount is removal of backup over max backups count
+	RemoveCount
+	// RemoveRetention is removal of backup expired by retention policy
+	RemoveRetention
+	// RemoveTotalSize is removal of backup over total size limit
+	RemoveTotalSize
+	// RemoveFreeSpace is removal of backup when free space is low
+	RemoveFreeSpace
+)
+
+var removeReasons = map[RemoveReason]string{
+	RemoveAge:       "age",
+	RemoveCount:     "count",
+	RemoveRetention: "retention",
+	RemoveTotalSize: "total size",
+	RemoveFreeSpace: "free space",
+}
+
+func (r RemoveReason) String() string {
+	return removeReasons[r]
+}
+
+// RemoveEvent describes removed backup
+type RemoveEvent struct {
+	// Filename is path of removed backup
+	Filename string
+	// Size of backup in bytes
+	Size int64
+	// Reason of removal
+	Reason RemoveReason
+	// Archived is true when backup was archived before removal
+	Archived bool
+}
+
+func (h Hooks) open(aEvent OpenEvent) {
+	if h.OnOpen != nil {
+		h.OnOpen(aEvent)
+	}
+}
+
+func (h Hooks) rotate(aEvent RotateEvent) {
+	if h.OnRotate != nil {
+		h.OnRotate(aEvent)
+	}
+}
+
+func (h Hooks) compress(aEvent CompressEvent) {
+	if h.OnCompress != nil {
+		h.OnCompress(aEvent)
+	}
+}
+
+func (h Hooks) remove(aEvent RemoveEvent) {
+	if h.OnRemove != nil {
+		h.OnRemove(aEvent)
+	}
+}
+
+// queueEvent keeps event of sweeping until locks released. Should be called
+// holding backupLock.
+func (l *Logger) queueEvent(aEvent interface{}) {
+	l.events = append(l.events, aEvent)
+}
+
+// fireEvents calls hooks for queued events. Should be called without locks,
+// so hooks may write to logger.
+func (l *Logger) fireEvents() {
+	l.backupLock.Lock()
+	events := l.events
+	l.events = nil
+	l.backupLock.Unlock()
+
+	for _, e := range events {
+		switch e := e.(type) {
+		case CompressEvent:
+			l.hooks.compress(e)
+		case RemoveEvent:
+			l.hooks.remove(e)
+		}
+	}
+}
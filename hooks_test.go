@@ -0,0 +1,173 @@
+package rollinglog
+
+import (
+	"io/ioutil"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recorder collects lifecycle events
+type recorder struct {
+	lock     sync.Mutex
+	opens    []OpenEvent
+	rotates  []RotateEvent
+	compress []CompressEvent
+	removes  []RemoveEvent
+}
+
+func (r *recorder) hooks() Hooks {
+	return Hooks{
+		OnOpen: func(e OpenEvent) {
+			r.lock.Lock()
+			defer r.lock.Unlock()
+			r.opens = append(r.opens, e)
+		},
+		OnRotate: func(e RotateEvent) {
+			r.lock.Lock()
+			defer r.lock.Unlock()
+			r.rotates = append(r.rotates, e)
+		},
+		OnCompress: func(e CompressEvent) {
+			r.lock.Lock()
+			defer r.lock.Unlock()
+			r.compress = append(r.compress, e)
+		},
+		OnRemove: func(e RemoveEvent) {
+			r.lock.Lock()
+			defer r.lock.Unlock()
+			r.removes = append(r.removes, e)
+		},
+	}
+}
+
+func TestHooks(t *testing.T) {
+	dir := makeTempDir("TestHooks", t)
+	defer os.RemoveAll(dir)
+
+	lf := logFile(dir)
+	require.NoError(t, ioutil.WriteFile(lf, []byte("1234\n"), fileMode))
+
+	r := &recorder{}
+	l := New(WithLogFile(lf), WithMaxBytes(10), WithMaxLines(2), WithMaxBackups(1),
+		UseCompression, WithHooks(r.hooks()))
+	defer l.Close()
+
+	// Opens existing file
+	_, err := l.Write([]byte("5\n"))
+	require.NoError(t, err)
+
+	// Rotates by lines, then by size
+	_, err = l.Write([]byte("6\n"))
+	require.NoError(t, err)
+	l.wg.Wait()
+
+	_, err = l.Write([]byte("123456789"))
+	require.NoError(t, err)
+	l.wg.Wait()
+
+	require.NoError(t, l.Rotate())
+	l.wg.Wait()
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	require.Equal(t, 4, len(r.opens))
+	assert.Equal(t, OpenEvent{Filename: lf, Size: 5}, r.opens[0])
+	assert.Equal(t, OpenEvent{Filename: lf, Created: true}, r.opens[1])
+
+	require.Equal(t, 3, len(r.rotates))
+	assert.Equal(t, RotateLines, r.rotates[0].Reason)
+	assert.Equal(t, uint64(7), r.rotates[0].State.Size)
+	assert.Equal(t, uint64(2), r.rotates[0].State.Lines)
+	assert.Equal(t, RotateSize, r.rotates[1].Reason)
+	assert.Equal(t, RotateForced, r.rotates[2].Reason)
+	assert.Equal(t, "forced", r.rotates[2].Reason.String())
+	for _, e := range r.rotates {
+		assert.Equal(t, lf, e.Filename)
+		assert.NotEqual(t, lf, e.Backup)
+	}
+
+	require.Equal(t, 3, len(r.compress))
+	assert.Equal(t, r.rotates[0].Backup, r.compress[0].Source)
+	assert.Equal(t, r.rotates[0].Backup+compressSuffix, r.compress[0].Destination)
+	assert.Equal(t, int64(7), r.compress[0].Size)
+	assert.True(t, r.compress[0].CompressedSize > 0)
+
+	require.Equal(t, 2, len(r.removes))
+	assert.Equal(t, r.compress[0].Destination, r.removes[0].Filename)
+	assert.Equal(t, RemoveCount, r.removes[0].Reason)
+	assert.Equal(t, r.compress[0].CompressedSize, r.removes[0].Size)
+	assert.False(t, r.removes[0].Archived)
+}
+
+func TestRemoveHookArchived(t *testing.T) {
+	dir := makeTempDir("TestRemoveHookArchived", t)
+	defer os.RemoveAll(dir)
+
+	r := &recorder{}
+	l := New(WithLogFile(logFile(dir)), WithMaxBytes(10), WithMaxBackups(1), WithHooks(r.hooks()),
+		WithArchiver(ArchiverFunc(func(string) error { return nil })))
+	defer l.Close()
+
+	b := []byte("123456789")
+	for i := 0; i < 3; i++ {
+		_, err := l.Write(b)
+		require.NoError(t, err)
+		l.wg.Wait()
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	require.Equal(t, 1, len(r.removes))
+	assert.True(t, r.removes[0].Archived)
+	assert.Equal(t, int64(9), r.removes[0].Size)
+	assert.Equal(t, "count", r.removes[0].Reason.String())
+}
+
+func TestHookWritesLogger(t *testing.T) {
+	dir := makeTempDir("TestHookWritesLogger", t)
+	defer os.RemoveAll(dir)
+
+	var l *Logger
+	removes := int32(0)
+	hooks := Hooks{
+		OnRemove: func(e RemoveEvent) {
+			// Write rotates log file, so each one causes next removal
+			if atomic.AddInt32(&removes, 1) > 3 {
+				return
+			}
+			_, err := l.Write([]byte("removed by hook\n"))
+			assert.NoError(t, err)
+		},
+	}
+
+	lf := logFile(dir)
+	l = New(WithLogFile(lf), WithMaxBytes(20), WithMaxBackups(1), UseCompression, WithHooks(hooks))
+
+	// Hooks called without locks, so writing from them doesn't hang
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			_, err := l.Write([]byte("123456789\n"))
+			assert.NoError(t, err)
+			l.wg.Wait()
+		}
+		assert.NoError(t, l.Close())
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "logger hangs when hook writes")
+	}
+
+	assert.True(t, atomic.LoadInt32(&removes) > 0)
+}
@@ -0,0 +1,37 @@
+package rollinglog
+
+import "time"
+
+// startJanitor starts periodic sweeping if required
+func (l *Logger) startJanitor() {
+	if l.sweepInterval <= 0 || l.janitorDone != nil {
+		return
+	}
+
+	l.janitorDone = make(chan struct{})
+
+	go l.runJanitor(l.sweepInterval, l.janitorDone)
+}
+
+func (l *Logger) stopJanitor() {
+	if l.janitorDone == nil {
+		return
+	}
+
+	close(l.janitorDone)
+	l.janitorDone = nil
+}
+
+func (l *Logger) runJanitor(aInterval time.Duration, aDone <-chan struct{}) {
+	ticker := time.NewTicker(aInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			l.onSweepTimer()
+		case <-aDone:
+			return
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package rollinglog
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSweepInterval(t *testing.T) {
+	dir := makeTempDir("TestSweepInterval", t)
+	defer os.RemoveAll(dir)
+
+	lf := logFile(dir)
+	l := New(WithLogFile(lf), WithMaxAge(1), WithSweepInterval(20*time.Millisecond))
+	defer l.Close()
+
+	// Started on open
+	assert.Nil(t, l.janitorDone)
+
+	b := []byte("123456789")
+	_, err := l.Write(b)
+	require.NoError(t, err)
+	require.NotNil(t, l.janitorDone)
+
+	// Backup of other instance expired without rotation of this one
+	prefix, suffix := splitFilename(lf)
+	old := time.Now().Add(-48 * time.Hour).UTC()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, prefix+old.Format(backupTimeFormat)+suffix), b, 0644))
+
+	assert.Eventually(t, func() bool {
+		count, err := getFilesInDir(t, dir)
+		return err == nil && count == 1
+	}, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, l.Close())
+	assert.Nil(t, l.janitorDone)
+}
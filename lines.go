@@ -0,0 +1,142 @@
+package rollinglog
+
+import (
+	"bytes"
+
+	"github.com/pkg/errors"
+)
+
+// OversizeMode defines how to handle writes exceeding size or lines limits
+type OversizeMode int
+
+const (
+	// RejectOversized rejects oversized writes with error
+	RejectOversized OversizeMode = iota
+	// SplitOversized splits oversized writes across files at new line boundaries
+	SplitOversized
+	// AllowOversized writes oversized data into fresh file which exceeds limits
+	AllowOversized
+)
+
+// oversized checks aData doesn't fit even into empty log file
+func (l *Logger) oversized(aData []byte) bool {
+	return sizeExceeded(uint64(len(aData)), l.sizeLimit) || sizeExceeded(newLines(aData), l.linesLimit)
+}
+
+func (l *Logger) oversizeError(aData []byte) error {
+	if writeLen := uint64(len(aData)); sizeExceeded(writeLen, l.sizeLimit) {
+		return errors.Errorf("write length %d exceeds file size limit %d", writeLen, l.sizeLimit)
+	}
+
+	writeLines := newLines(aData)
+	return errors.Errorf("write lines %d exceeds file lines limit %d", writeLines, l.linesLimit)
+}
+
+// fitLines returns longest part of aData which ends with new line (or whole aData)
+// and fits into current log file
+func (l *Logger) fitLines(aData []byte) []byte {
+	fit := 0
+	lines := uint64(0)
+
+	for fit < len(aData) {
+		next := len(aData)
+		if i := bytes.IndexByte(aData[fit:], '\n'); i >= 0 {
+			next = fit + i + 1
+			lines++
+		}
+
+		if sizeExceeded(l.state.Size+uint64(next), l.sizeLimit) ||
+			sizeExceeded(l.state.Lines+lines, l.linesLimit) {
+			break
+		}
+		fit = next
+	}
+
+	return aData[:fit]
+}
+
+// firstLine returns first line of aData with new line
+func firstLine(aData []byte) []byte {
+	if i := bytes.IndexByte(aData, '\n'); i >= 0 {
+		return aData[:i+1]
+	}
+	return aData
+}
+
+// writeSplit writes aData rotating log file at new line boundaries only.
+// Line which doesn't fit into empty file handled according to oversize mode.
+func (l *Logger) writeSplit(p []byte) (n int, err error) {
+	if l.file == nil {
+		if err = l.openFile(nil); err != nil {
+			return 0, errors.Wrap(err, "write failed")
+		}
+	}
+
+	for len(p) > 0 {
+		chunk := l.fitLines(p)
+
+		if len(chunk) == 0 {
+			if l.state.Size > 0 {
+				reason, _ := l.rotationReason(l.state, firstLine(p))
+				if err = l.rotateFile(reason); err != nil {
+					return n, err
+				}
+				continue
+			}
+
+			chunk = firstLine(p)
+			if l.oversize == RejectOversized {
+				return n, l.oversizeError(chunk)
+			}
+		}
+
+		w, err := l.write(chunk)
+		n += w
+		if err != nil {
+			return n, err
+		}
+		p = p[w:]
+	}
+
+	return n, nil
+}
+
+// maxPendingLine limits partial line kept by line buffering, so memory
+// doesn't grow without bound when new line never arrives
+const maxPendingLine = 64 * 1024
+
+// writeBuffered keeps partial line in buffer until new line arrives and
+// writes complete lines only
+func (l *Logger) writeBuffered(p []byte) (int, error) {
+	l.pending = append(l.pending, p...)
+
+	end := bytes.LastIndexByte(l.pending, '\n') + 1
+	if end == 0 && (len(l.pending) > maxPendingLine || sizeExceeded(uint64(len(l.pending)), l.sizeLimit)) {
+		// Line without end is too long to wait more
+		end = len(l.pending)
+	}
+
+	if end == 0 {
+		return len(p), nil
+	}
+
+	_, err := l.writeSplit(l.pending[:end])
+
+	// Data consumed by buffer in any case, so drop failed lines to avoid
+	// repeating errors on each write
+	l.pending = append(l.pending[:0], l.pending[end:]...)
+
+	return len(p), err
+}
+
+// flushPending writes buffered partial line
+func (l *Logger) flushPending() error {
+	if len(l.pending) == 0 {
+		return nil
+	}
+
+	_, err := l.writeSplit(l.pending)
+	l.pending = l.pending[:0]
+
+	return err
+}
@@ -0,0 +1,180 @@
+package rollinglog
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFitLines(t *testing.T) {
+	l := New(WithMaxBytes(10), WithMaxLines(2))
+
+	assert.Equal(t, []byte("123\n456\n"), l.fitLines([]byte("123\n456\n789\n")))
+	assert.Equal(t, []byte("123\n45"), l.fitLines([]byte("123\n45")))
+	assert.Equal(t, []byte("1234\n"), l.fitLines([]byte("1234\n123456\n")))
+	assert.Equal(t, []byte{}, l.fitLines([]byte("12345678901\n")))
+
+	l.state.Size = 5
+	assert.Equal(t, []byte("123\n"), l.fitLines([]byte("123\n456\n")))
+
+	l.state.Lines = 2
+	assert.Equal(t, []byte("12"), l.fitLines([]byte("12")))
+	assert.Equal(t, []byte{}, l.fitLines([]byte("1\n")))
+}
+
+func TestFirstLine(t *testing.T) {
+	assert.Equal(t, []byte("12\n"), firstLine([]byte("12\n34\n")))
+	assert.Equal(t, []byte("1234"), firstLine([]byte("1234")))
+}
+
+func TestSplitOversized(t *testing.T) {
+	dir := makeTempDir("TestSplitOversized", t)
+	defer os.RemoveAll(dir)
+
+	lf := logFile(dir)
+	l := New(WithLogFile(lf), WithMaxBytes(10), WithOversizedWrites(SplitOversized))
+
+	_, err := l.Write([]byte("1234\n"))
+	require.NoError(t, err)
+
+	b := []byte("5678\n12345678901234\n")
+	n, err := l.Write(b)
+	require.NoError(t, err)
+	assert.Equal(t, len(b), n)
+
+	<-time.After(time.Millisecond * 10)
+
+	_, err = l.Write([]byte("5678\n"))
+	require.NoError(t, err)
+	require.NoError(t, l.Close())
+
+	assert.Equal(t, []string{"1234\n5678\n", "12345678901234\n", "5678\n"}, readBackups(t, dir, lf))
+}
+
+func TestAllowOversized(t *testing.T) {
+	dir := makeTempDir("TestAllowOversized", t)
+	defer os.RemoveAll(dir)
+
+	lf := logFile(dir)
+	l := New(WithLogFile(lf), WithMaxBytes(10), WithOversizedWrites(AllowOversized))
+
+	_, err := l.Write([]byte("1234\n"))
+	require.NoError(t, err)
+
+	b := []byte("5678\n1234\n5678\n")
+	n, err := l.Write(b)
+	require.NoError(t, err)
+	assert.Equal(t, len(b), n)
+
+	<-time.After(time.Millisecond * 10)
+
+	_, err = l.Write([]byte("1234\n"))
+	require.NoError(t, err)
+	require.NoError(t, l.Close())
+
+	assert.Equal(t, []string{"1234\n", "5678\n1234\n5678\n", "1234\n"}, readBackups(t, dir, lf))
+}
+
+func TestLineBuffering(t *testing.T) {
+	dir := makeTempDir("TestLineBuffering", t)
+	defer os.RemoveAll(dir)
+
+	lf := logFile(dir)
+	l := New(WithLogFile(lf), WithMaxBytes(10), UseLineBuffering)
+
+	n, err := l.Write([]byte("12"))
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	_, err = os.Stat(lf)
+	assert.True(t, os.IsNotExist(err), "partial line written")
+
+	_, err = l.Write([]byte("34\n56"))
+	require.NoError(t, err)
+	existsWithContent(lf, []byte("1234\n"), t)
+
+	_, err = l.Write([]byte("78\n9"))
+	require.NoError(t, err)
+	existsWithContent(lf, []byte("1234\n5678\n"), t)
+
+	// Too long line rejected by default
+	_, err = l.Write([]byte("12345678901\n"))
+	assert.EqualError(t, err, "write length 13 exceeds file size limit 10")
+
+	_, err = l.Write([]byte("1\n"))
+	require.NoError(t, err)
+
+	// Partial line flushed on close
+	_, err = l.Write([]byte("end"))
+	require.NoError(t, err)
+	require.NoError(t, l.Close())
+
+	assert.Equal(t, []string{"1234\n5678\n", "1\nend"}, readBackups(t, dir, lf))
+}
+
+func TestLineBufferingLongLine(t *testing.T) {
+	dir := makeTempDir("TestLineBufferingLongLine", t)
+	defer os.RemoveAll(dir)
+
+	lf := logFile(dir)
+	l := New(WithLogFile(lf), WithMaxBytes(10), UseLineBuffering, WithOversizedWrites(AllowOversized))
+
+	_, err := l.Write([]byte("123"))
+	require.NoError(t, err)
+
+	// Line without end flushed when exceeds limit
+	_, err = l.Write([]byte("45678901"))
+	require.NoError(t, err)
+	existsWithContent(lf, []byte("12345678901"), t)
+
+	require.NoError(t, l.Close())
+}
+
+func TestLineBufferingPendingLimit(t *testing.T) {
+	dir := makeTempDir("TestLineBufferingPendingLimit", t)
+	defer os.RemoveAll(dir)
+
+	lf := logFile(dir)
+	l := New(WithLogFile(lf), UseLineBuffering)
+	defer l.Close()
+
+	b := bytes.Repeat([]byte("1"), maxPendingLine)
+	_, err := l.Write(b)
+	require.NoError(t, err)
+
+	_, err = os.Stat(lf)
+	assert.True(t, os.IsNotExist(err), "partial line written")
+
+	// Line without end flushed when exceeds pending limit without size limit
+	_, err = l.Write([]byte("2"))
+	require.NoError(t, err)
+	existsWithContent(lf, append(b, '2'), t)
+	assert.Equal(t, 0, len(l.pending))
+}
+
+// readBackups returns content of backups and log file ordered by time
+func readBackups(t testing.TB, aDir, aLogFile string) []string {
+	backups, err := filterBackups(aDir, filepath.Base(aLogFile), 0, defaultNamer)
+	require.NoError(t, err)
+
+	sort.Sort(sort.Reverse(byTimestamp(backups)))
+
+	result := []string{}
+	for _, b := range backups {
+		data, err := ioutil.ReadFile(filepath.Join(aDir, b.name))
+		require.NoError(t, err)
+		result = append(result, string(data))
+	}
+
+	data, err := ioutil.ReadFile(aLogFile)
+	require.NoError(t, err)
+
+	return append(result, string(data))
+}
@@ -0,0 +1,133 @@
+package rollinglog
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+)
+
+const (
+	lockSuffix      = ".lock"
+	sweepLockSuffix = ".sweep.lock"
+)
+
+// acquireProcessLock opens aFilename and locks it exclusively. Lock is shared
+// between processes and between different acquisitions in one process.
+func acquireProcessLock(aFilename string) (*os.File, error) {
+	dir := filepath.Dir(aFilename)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.Wrapf(err, "can't make directories for %s", dir)
+	}
+
+	f, err := os.OpenFile(aFilename, os.O_CREATE|os.O_RDWR, fileMode)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't open lock file %s", aFilename)
+	}
+
+	if err = lockFile(f); err != nil {
+		f.Close()
+		return nil, errors.Wrapf(err, "can't lock %s", aFilename)
+	}
+
+	return f, nil
+}
+
+func releaseProcessLock(aLock *os.File) error {
+	errs := new(multierror.Error)
+	errs = multierror.Append(errs, unlockFile(aLock))
+	errs = multierror.Append(errs, aLock.Close())
+
+	return errs.ErrorOrNil()
+}
+
+// withProcessLock runs aAction holding lock on sidecar file of log file
+// if process lock is enabled
+func (l *Logger) withProcessLock(aSuffix string, aAction func() error) error {
+	if !l.processLock {
+		return aAction()
+	}
+
+	lock, err := acquireProcessLock(l.lockName(aSuffix))
+	if err != nil {
+		return err
+	}
+
+	err = aAction()
+	if e := releaseProcessLock(lock); e != nil && err == nil {
+		err = errors.Wrapf(e, "can't release lock for %s", l.logFilename())
+	}
+
+	return err
+}
+
+// withSweepLock runs aAction holding sweeping lock inside the process and
+// between processes if process lock is enabled
+func (l *Logger) withSweepLock(aAction func() error) error {
+	l.sweepLock.Lock()
+	defer l.sweepLock.Unlock()
+
+	return l.withProcessLock(sweepLockSuffix, aAction)
+}
+
+// withRotationLock runs aAction holding rotation lock if process lock is enabled.
+// State of log file refreshed before aAction, because other processes could
+// write or rotate it, and buffered data written after.
+func (l *Logger) withRotationLock(aAction func() error) error {
+	if err := l.checkPattern(); err != nil {
+		return err
+	}
+
+	if !l.processLock {
+		return aAction()
+	}
+
+	return l.withProcessLock(lockSuffix, func() error {
+		err := l.refreshState()
+		if err == nil {
+			err = aAction()
+		}
+
+		if e := l.flushFile(); e != nil && err == nil {
+			err = e
+		}
+
+		return err
+	})
+}
+
+// refreshState re-reads size of log file and reopens it if other
+// process rotated it. Lines recounted when other process wrote to log file.
+func (l *Logger) refreshState() error {
+	if l.file == nil {
+		return nil
+	}
+
+	opened, err := l.file.Stat()
+	if err != nil {
+		return errors.Wrapf(err, "can't stat opened %s", l.filename)
+	}
+
+	info, err := os.Stat(l.filename)
+	if err == nil && os.SameFile(opened, info) {
+		size := uint64(info.Size())
+		if size == l.state.Size {
+			return nil
+		}
+		l.state.Size = size
+
+		// Lines are required by limit or policy only
+		if l.linesLimit > 0 || l.policy != nil {
+			if l.state.Lines, err = countLines(l.filename); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "can't stat %s", l.filename)
+	}
+
+	return l.reopen()
+}
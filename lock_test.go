@@ -0,0 +1,136 @@
+package rollinglog
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcquireProcessLock(t *testing.T) {
+	if !processLockSupported {
+		t.Skip("process lock is not supported")
+	}
+
+	dir := makeTempDir("TestAcquireProcessLock", t)
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "foobar.log.lock")
+
+	first, err := acquireProcessLock(name)
+	require.NoError(t, err)
+
+	locked := make(chan struct{})
+	go func() {
+		second, err := acquireProcessLock(name)
+		require.NoError(t, err)
+		close(locked)
+		require.NoError(t, releaseProcessLock(second))
+	}()
+
+	select {
+	case <-locked:
+		t.Fatal("lock acquired twice")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	require.NoError(t, releaseProcessLock(first))
+	<-locked
+}
+
+func TestProcessLock(t *testing.T) {
+	if !processLockSupported {
+		t.Skip("process lock is not supported")
+	}
+
+	dir := makeTempDir("TestProcessLock", t)
+	defer os.RemoveAll(dir)
+
+	// Two loggers simulate two processes
+	lf := logFile(dir)
+	l1 := New(WithLogFile(lf), WithMaxBytes(30), UseProcessLock)
+	l2 := New(WithLogFile(lf), WithMaxBytes(30), UseProcessLock)
+
+	expected := 0
+	for i := 0; i < 10; i++ {
+		for _, l := range []*Logger{l1, l2} {
+			<-time.After(2 * time.Millisecond)
+
+			n, err := l.Write([]byte("123456789\n"))
+			require.NoError(t, err)
+			expected += n
+		}
+	}
+
+	require.NoError(t, l1.Close())
+	require.NoError(t, l2.Close())
+
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+
+	total := 0
+	for _, f := range files {
+		if strings.HasSuffix(f.Name(), lockSuffix) {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		require.NoError(t, err)
+		assert.True(t, len(data) <= 30, "file %s exceeds limit", f.Name())
+		assert.Equal(t, 0, len(bytes.Replace(data, []byte("123456789\n"), nil, -1)))
+		total += len(data)
+	}
+
+	assert.Equal(t, expected, total)
+
+	// 200 bytes with 3 lines per file
+	assert.Equal(t, 7, len(files)-1)
+}
+
+func TestProcessLockLines(t *testing.T) {
+	if !processLockSupported {
+		t.Skip("process lock is not supported")
+	}
+
+	dir := makeTempDir("TestProcessLockLines", t)
+	defer os.RemoveAll(dir)
+
+	// Two loggers simulate two processes
+	lf := logFile(dir)
+	l1 := New(WithLogFile(lf), WithMaxLines(4), UseProcessLock)
+	l2 := New(WithLogFile(lf), WithMaxLines(4), UseProcessLock)
+
+	for i := 0; i < 10; i++ {
+		for _, l := range []*Logger{l1, l2} {
+			<-time.After(2 * time.Millisecond)
+
+			_, err := l.Write([]byte("1\n"))
+			require.NoError(t, err)
+		}
+	}
+
+	require.NoError(t, l1.Close())
+	require.NoError(t, l2.Close())
+
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+
+	for _, f := range files {
+		if strings.HasSuffix(f.Name(), lockSuffix) {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		require.NoError(t, err)
+		assert.Equal(t, "1\n1\n1\n1\n", string(data), "file %s", f.Name())
+	}
+
+	// 20 lines with 4 lines per file
+	assert.Equal(t, 5, len(files)-1)
+}
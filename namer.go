@@ -0,0 +1,340 @@
+package rollinglog
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// BackupNamer defines names of backups. Names are given without directory
+// and compression suffix.
+type BackupNamer interface {
+	// Name returns name of backup of log file aLogName rotated at aTime.
+	// Greater aSeq requested when backup with name for lower one exists,
+	// so names for different aSeq should differ.
+	Name(aLogName string, aTime time.Time, aSeq int) string
+	// Parse returns time and sequence number of backup aBackupName of log
+	// file aLogName or error when it's not a backup. Zero time means that
+	// modification time of backup used instead. Backups with the same time
+	// ordered by sequence number, greater is newer.
+	Parse(aLogName, aBackupName string) (time.Time, int, error)
+}
+
+// BackupShifter is implemented by namers which rename existing backups
+// before new backup created (e.g. numbered backups)
+type BackupShifter interface {
+	// Shift renames backups of log file aLogName in aDir. Names of compressed
+	// backups end with one of aCompressedSuffixes.
+	Shift(aDir, aLogName string, aCompressedSuffixes []string) error
+}
+
+// defaultNamer names backups like name.20060102150405.000.ext
+var defaultNamer = TimeNamer(backupTimeFormat)
+
+type timeNamer struct {
+	layout string
+}
+
+// TimeNamer names backups like name.<time>.ext where time formatted with
+// aLayout (see time.Format). Sequence number added after time when several
+// backups have the same name, e.g. name.<time>-1.ext
+func TimeNamer(aLayout string) BackupNamer {
+	return timeNamer{layout: aLayout}
+}
+
+func (n timeNamer) Name(aLogName string, aTime time.Time, aSeq int) string {
+	prefix, suffix := splitFilename(aLogName)
+	return prefix + aTime.Format(n.layout) + seqSuffix(aSeq) + suffix
+}
+
+func (n timeNamer) Parse(aLogName, aBackupName string) (time.Time, int, error) {
+	return n.parseIn(aLogName, aBackupName, time.UTC)
+}
+
+func (n timeNamer) parseIn(aLogName, aBackupName string, aLocation *time.Location) (time.Time, int, error) {
+	prefix, suffix := splitFilename(aLogName)
+	return backupID(aBackupName, prefix, suffix, n.layout, aLocation)
+}
+
+type numberedNamer struct{}
+
+// NumberedNamer names backups like name.1.ext, name.2.ext, ... where
+// name.1.ext is the newest one. Existing backups shifted on rotation.
+func NumberedNamer() BackupNamer {
+	return numberedNamer{}
+}
+
+func (n numberedNamer) Name(aLogName string, aTime time.Time, aSeq int) string {
+	prefix, suffix := splitFilename(aLogName)
+	return prefix + strconv.Itoa(aSeq+1) + suffix
+}
+
+// Parse returns negative index, so backups with lower index are newer
+func (n numberedNamer) Parse(aLogName, aBackupName string) (time.Time, int, error) {
+	prefix, suffix := splitFilename(aLogName)
+
+	id, err := backupIDPart(aBackupName, prefix, suffix)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+
+	index, err := parseSeq("-" + id)
+	if err != nil {
+		return time.Time{}, 0, errors.New("invalid index field")
+	}
+
+	return time.Time{}, -index, nil
+}
+
+func (n numberedNamer) Shift(aDir, aLogName string, aCompressedSuffixes []string) error {
+	files, err := ioutil.ReadDir(aDir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "can't read backups directory: %s", aDir)
+	}
+
+	type numbered struct {
+		index  int
+		suffix string
+	}
+
+	backups := []numbered{}
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+
+		for _, cs := range append([]string{""}, aCompressedSuffixes...) {
+			if !strings.HasSuffix(f.Name(), cs) {
+				continue
+			}
+			if _, seq, err := n.Parse(aLogName, strings.TrimSuffix(f.Name(), cs)); err == nil {
+				backups = append(backups, numbered{-seq, cs})
+				break
+			}
+		}
+	}
+
+	// Highest index renamed first to free name for next one
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].index > backups[j].index
+	})
+
+	for _, b := range backups {
+		from := filepath.Join(aDir, n.Name(aLogName, time.Time{}, b.index-1)+b.suffix)
+		to := filepath.Join(aDir, n.Name(aLogName, time.Time{}, b.index)+b.suffix)
+		if err := os.Rename(from, to); err != nil {
+			return errors.Wrapf(err, "can't shift backup %s", from)
+		}
+	}
+
+	return nil
+}
+
+// Placeholders of backup name template
+const (
+	namePlaceholder = "{name}"
+	extPlaceholder  = "{ext}"
+	timePlaceholder = "{time}"
+	hostPlaceholder = "{host}"
+	pidPlaceholder  = "{pid}"
+	seqPlaceholder  = "{seq}"
+)
+
+var placeholderRe = regexp.MustCompile(`\{(name|ext|time|host|pid|seq)\}`)
+
+type templateNamer struct {
+	template string
+	layout   string
+	host     string
+	pid      string
+
+	lock    sync.Mutex
+	logName string
+	parsers []*regexp.Regexp
+}
+
+// TemplateNamer names backups by aTemplate with placeholders:
+//
+//	{name} - log file name without extension
+//	{ext}  - log file extension with dot
+//	{time} - rotation time formatted with aLayout (see time.Format)
+//	{host} - host name
+//	{pid}  - process id
+//	{seq}  - sequence number, empty when backup name is unique, -N otherwise
+//
+// Sequence number added after time (or to the end) when template has no {seq}.
+// Only backups with current host name recognised as backups of log file.
+// E.g. TemplateNamer("{name}-{host}-{time}{ext}", "2006-01-02T150405")
+func TemplateNamer(aTemplate, aLayout string) BackupNamer {
+	if !strings.Contains(aTemplate, seqPlaceholder) {
+		if strings.Contains(aTemplate, timePlaceholder) {
+			aTemplate = strings.Replace(aTemplate, timePlaceholder, timePlaceholder+seqPlaceholder, 1)
+		} else {
+			aTemplate += seqPlaceholder
+		}
+	}
+
+	host, err := os.Hostname()
+	if err != nil {
+		host = "localhost"
+	}
+
+	return &templateNamer{
+		template: aTemplate,
+		layout:   aLayout,
+		host:     host,
+		pid:      strconv.Itoa(os.Getpid()),
+	}
+}
+
+func (n *templateNamer) Name(aLogName string, aTime time.Time, aSeq int) string {
+	name, ext := splitExt(aLogName)
+
+	return placeholderRe.ReplaceAllStringFunc(n.template, func(aPlaceholder string) string {
+		switch aPlaceholder {
+		case namePlaceholder:
+			return name
+		case extPlaceholder:
+			return ext
+		case timePlaceholder:
+			return aTime.Format(n.layout)
+		case hostPlaceholder:
+			return n.host
+		case pidPlaceholder:
+			return n.pid
+		}
+		return seqSuffix(aSeq)
+	})
+}
+
+func (n *templateNamer) Parse(aLogName, aBackupName string) (time.Time, int, error) {
+	return n.parseIn(aLogName, aBackupName, time.UTC)
+}
+
+func (n *templateNamer) parseIn(aLogName, aBackupName string, aLocation *time.Location) (time.Time, int, error) {
+	var err error
+
+	for _, re := range n.backupParsers(aLogName) {
+		m := re.FindStringSubmatch(aBackupName)
+		if m == nil {
+			continue
+		}
+
+		ts, seq := time.Time{}, 0
+		err = nil
+
+		for i, group := range re.SubexpNames() {
+			switch {
+			case group == "time" && err == nil:
+				ts, err = time.ParseInLocation(n.layout, m[i], aLocation)
+			case group == "seq" && m[i] != "":
+				seq, _ = parseSeq(m[i])
+			}
+		}
+
+		if err == nil {
+			return ts, seq, nil
+		}
+	}
+
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	return time.Time{}, 0, errors.New("mismatch template")
+}
+
+// backupParsers returns expressions matching backups of aLogName. Time in
+// name may be ambiguous with following sequence number, so shortest and
+// longest times tried both.
+func (n *templateNamer) backupParsers(aLogName string) []*regexp.Regexp {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if n.parsers != nil && n.logName == aLogName {
+		return n.parsers
+	}
+
+	name, ext := splitExt(aLogName)
+
+	expr := ""
+	last := 0
+	for _, loc := range placeholderRe.FindAllStringIndex(n.template, -1) {
+		expr += regexp.QuoteMeta(n.template[last:loc[0]])
+		last = loc[1]
+
+		switch n.template[loc[0]:loc[1]] {
+		case namePlaceholder:
+			expr += regexp.QuoteMeta(name)
+		case extPlaceholder:
+			expr += regexp.QuoteMeta(ext)
+		case timePlaceholder:
+			expr += `(?P<time>.+)`
+		case hostPlaceholder:
+			expr += regexp.QuoteMeta(n.host)
+		case pidPlaceholder:
+			expr += `[0-9]+`
+		case seqPlaceholder:
+			expr += `(?P<seq>-[1-9][0-9]*)?`
+		}
+	}
+	expr = "^" + expr + regexp.QuoteMeta(n.template[last:]) + "$"
+
+	n.logName = aLogName
+	n.parsers = []*regexp.Regexp{
+		regexp.MustCompile(expr),
+		regexp.MustCompile("(?U)" + expr),
+	}
+
+	return n.parsers
+}
+
+// splitExt returns name of log file without extension and extension
+func splitExt(aLogName string) (string, string) {
+	ext := filepath.Ext(aLogName)
+	return aLogName[:len(aLogName)-len(ext)], ext
+}
+
+// backupNamer returns namer for backups
+func (l *Logger) backupNamer() BackupNamer {
+	if l.namer == nil {
+		return defaultNamer
+	}
+	return l.namer
+}
+
+// locationParser is implemented by namers which parse time in given location
+type locationParser interface {
+	parseIn(aLogName, aBackupName string, aLocation *time.Location) (time.Time, int, error)
+}
+
+// localNamer parses backup times of wrapped namer in local time, so names
+// formatted with UseLocaltime read back as the same instant
+type localNamer struct {
+	BackupNamer
+}
+
+func (n localNamer) Parse(aLogName, aBackupName string) (time.Time, int, error) {
+	if p, ok := n.BackupNamer.(locationParser); ok {
+		return p.parseIn(aLogName, aBackupName, time.Local)
+	}
+	return n.BackupNamer.Parse(aLogName, aBackupName)
+}
+
+// parsingNamer returns namer for parsing backup names in logger's timezone
+func (l *Logger) parsingNamer() BackupNamer {
+	if l.localtime {
+		return localNamer{l.backupNamer()}
+	}
+	return l.backupNamer()
+}
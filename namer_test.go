@@ -0,0 +1,239 @@
+package rollinglog
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeNamer(t *testing.T) {
+	n := TimeNamer("2006-01-02")
+	ts := time.Date(2019, 11, 14, 0, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, "app.2019-11-14.log", n.Name("app.log", ts, 0))
+	assert.Equal(t, "app.2019-11-14-2.log", n.Name("app.log", ts, 2))
+
+	tests := []struct {
+		filename string
+		wantSeq  int
+		wantErr  bool
+	}{
+		{"app.2019-11-14.log", 0, false},
+		{"app.2019-11-14-2.log", 2, false},
+		{"app.2019-11-14-02.log", 0, true},
+		{"app.2019-11.log", 0, true},
+		{"app.2019-11-14.txt", 0, true},
+		{"app.log", 0, true},
+	}
+
+	for _, test := range tests {
+		got, seq, err := n.Parse("app.log", test.filename)
+		assert.Equal(t, test.wantErr, err != nil, test.filename)
+		if !test.wantErr {
+			assert.Equal(t, ts, got, test.filename)
+			assert.Equal(t, test.wantSeq, seq, test.filename)
+		}
+	}
+}
+
+func TestNumberedNamer(t *testing.T) {
+	n := NumberedNamer()
+
+	assert.Equal(t, "app.1.log", n.Name("app.log", time.Now(), 0))
+	assert.Equal(t, "app.3.log", n.Name("app.log", time.Now(), 2))
+
+	tests := []struct {
+		filename string
+		wantSeq  int
+		wantErr  bool
+	}{
+		{"app.1.log", -1, false},
+		{"app.12.log", -12, false},
+		{"app.0.log", 0, true},
+		{"app.01.log", 0, true},
+		{"app.1a.log", 0, true},
+		{"app.log", 0, true},
+	}
+
+	for _, test := range tests {
+		got, seq, err := n.Parse("app.log", test.filename)
+		assert.Equal(t, test.wantErr, err != nil, test.filename)
+		assert.True(t, got.IsZero(), test.filename)
+		assert.Equal(t, test.wantSeq, seq, test.filename)
+	}
+}
+
+func TestNumberedBackups(t *testing.T) {
+	dir := makeTempDir("TestNumberedBackups", t)
+	defer os.RemoveAll(dir)
+
+	lf := logFile(dir)
+	l := New(WithLogFile(lf), WithMaxBytes(10), WithMaxBackups(2), WithBackupNamer(NumberedNamer()))
+
+	for i := 1; i <= 4; i++ {
+		_, err := l.Write([]byte(fmt.Sprintf("%d23456789", i)))
+		require.NoError(t, err)
+	}
+
+	// Wait for sweeping
+	<-time.After(time.Millisecond * 10)
+	require.NoError(t, l.Close())
+
+	count, err := getFilesInDir(t, dir)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	existsWithContent(lf, []byte("423456789"), t)
+	existsWithContent(filepath.Join(dir, "foobar.1.log"), []byte("323456789"), t)
+	existsWithContent(filepath.Join(dir, "foobar.2.log"), []byte("223456789"), t)
+}
+
+func TestNumberedBackupsCompression(t *testing.T) {
+	dir := makeTempDir("TestNumberedBackupsCompression", t)
+	defer os.RemoveAll(dir)
+
+	lf := logFile(dir)
+	l := New(WithLogFile(lf), WithMaxBytes(10), UseCompression, WithBackupNamer(NumberedNamer()))
+
+	for i := 1; i <= 4; i++ {
+		_, err := l.Write([]byte(fmt.Sprintf("%d23456789", i)))
+		require.NoError(t, err)
+	}
+
+	// Wait for sweeping
+	l.wg.Wait()
+	require.NoError(t, l.Close())
+
+	count, err := gzFileCount(t, dir)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	for i := 1; i <= 3; i++ {
+		f, err := os.Open(filepath.Join(dir, "foobar."+strconv.Itoa(i)+".log"+compressSuffix))
+		require.NoError(t, err)
+
+		gz, err := gzip.NewReader(f)
+		require.NoError(t, err)
+
+		data, err := ioutil.ReadAll(gz)
+		require.NoError(t, err)
+		f.Close()
+
+		assert.Equal(t, fmt.Sprintf("%d23456789", 4-i), string(data))
+	}
+}
+
+func TestTemplateNamer(t *testing.T) {
+	host, err := os.Hostname()
+	require.NoError(t, err)
+
+	n := TemplateNamer("{name}-{host}-{pid}-{time}{ext}", "2006-01-02")
+	ts := time.Date(2019, 11, 14, 0, 0, 0, 0, time.UTC)
+	pid := strconv.Itoa(os.Getpid())
+
+	name := n.Name("app.log", ts, 0)
+	assert.Equal(t, "app-"+host+"-"+pid+"-2019-11-14.log", name)
+	assert.Equal(t, "app-"+host+"-"+pid+"-2019-11-14-3.log", n.Name("app.log", ts, 3))
+
+	tests := []struct {
+		filename string
+		wantSeq  int
+		wantErr  bool
+	}{
+		{"app-" + host + "-" + pid + "-2019-11-14.log", 0, false},
+		{"app-" + host + "-1-2019-11-14-3.log", 3, false},
+		{"app-" + host + "-1-2019-11-14-03.log", 0, true},
+		{"app-" + host + "x-1-2019-11-14.log", 0, true},
+		{"app-" + host + "-1-2019-11-14.txt", 0, true},
+		{"app.log", 0, true},
+	}
+
+	for _, test := range tests {
+		got, seq, err := n.Parse("app.log", test.filename)
+		assert.Equal(t, test.wantErr, err != nil, test.filename)
+		if !test.wantErr {
+			assert.Equal(t, ts, got, test.filename)
+			assert.Equal(t, test.wantSeq, seq, test.filename)
+		}
+	}
+
+	// Without time
+	n = TemplateNamer("old-{name}{ext}", "")
+	assert.Equal(t, "old-app.log", n.Name("app.log", ts, 0))
+	assert.Equal(t, "old-app.log-1", n.Name("app.log", ts, 1))
+
+	got, seq, err := n.Parse("app.log", "old-app.log-1")
+	require.NoError(t, err)
+	assert.True(t, got.IsZero())
+	assert.Equal(t, 1, seq)
+}
+
+func TestTemplateBackups(t *testing.T) {
+	dir := makeTempDir("TestTemplateBackups", t)
+	defer os.RemoveAll(dir)
+
+	now := time.Date(2019, 11, 4, 18, 30, 0, 0, time.UTC)
+	currentTime = func() time.Time { return now }
+	defer func() { currentTime = time.Now }()
+
+	lf := logFile(dir)
+	n := TemplateNamer("{time}-{name}{ext}", "20060102")
+	l := New(WithLogFile(lf), WithMaxBytes(10), WithMaxBackups(2), WithBackupNamer(n))
+
+	for i := 1; i <= 4; i++ {
+		_, err := l.Write([]byte(fmt.Sprintf("%d23456789", i)))
+		require.NoError(t, err)
+	}
+
+	// Wait for sweeping
+	<-time.After(time.Millisecond * 10)
+	require.NoError(t, l.Close())
+
+	backups, err := filterBackups(dir, filepath.Base(lf), 0, n)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(backups))
+
+	assert.Equal(t, "20191104-2-foobar.log", backups[0].name)
+	assert.Equal(t, "20191104-1-foobar.log", backups[1].name)
+	existsWithContent(filepath.Join(dir, backups[0].name), []byte("323456789"), t)
+	existsWithContent(filepath.Join(dir, backups[1].name), []byte("223456789"), t)
+}
+
+func TestLocaltimeBackups(t *testing.T) {
+	dir := makeTempDir("TestLocaltimeBackups", t)
+	defer os.RemoveAll(dir)
+
+	local := time.Local
+	time.Local = time.FixedZone("UTC-5", -5*60*60)
+	defer func() { time.Local = local }()
+
+	now := time.Date(2026, 10, 16, 10, 0, 0, 0, time.Local)
+	currentTime = func() time.Time { return now }
+	defer func() { currentTime = time.Now }()
+
+	namers := []BackupNamer{defaultNamer, TemplateNamer("{name}-{time}{ext}", "2006-01-02T150405")}
+	for i, n := range namers {
+		lf := filepath.Join(dir, fmt.Sprintf("app%d.log", i))
+		l := New(WithLogFile(lf), WithBackupNamer(n), WithMaxAgeDuration(time.Hour), UseLocaltime)
+
+		_, err := l.Write([]byte("123456789"))
+		require.NoError(t, err)
+		require.NoError(t, l.Rotate())
+		l.wg.Wait()
+
+		// Backup named in local time parsed as the same instant, so it's fresh
+		backups, err := l.backups()
+		require.NoError(t, err)
+		require.Equal(t, 1, len(backups), "backup of %s removed", lf)
+		assert.True(t, backups[0].timestamp.Equal(now), backups[0].timestamp.String())
+		require.NoError(t, l.Close())
+	}
+}
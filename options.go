@@ -1,5 +1,10 @@
 package rollinglog
 
+import (
+	"os"
+	"time"
+)
+
 // Option func type
 type Option func(l *Logger)
 
@@ -12,10 +17,18 @@ func Options(opts ...Option) Option {
 	}
 }
 
-// WithLogFile sets output file name with path
+// WithLogFile sets output file name with path. File name (but not directory)
+// may contain time directives %Y, %m, %d, %H, %M, %S (and %% for percent
+// sign), e.g. "app-%Y-%m-%d.log". Logger switches to new file on write when
+// rendered name changes, and files of previous periods are treated as
+// backups. Writes fail when directory contains directives.
 func WithLogFile(aFilename string) Option {
 	return func(l *Logger) {
 		l.filename = aFilename
+		l.pattern = ""
+		if isPattern(aFilename) {
+			l.pattern = aFilename
+		}
 	}
 }
 
@@ -27,6 +40,58 @@ func WithMaxBytes(aSize uint64) Option {
 	}
 }
 
+// WithMaxLines limits count of lines in log. When limit exceeded log will be rotated.
+// (0 - no limit)
+func WithMaxLines(aCount uint64) Option {
+	return func(l *Logger) {
+		l.linesLimit = aCount
+	}
+}
+
+// WithRotationPolicy sets custom policy for rotation. Policy checked in addition
+// to size and time limits.
+func WithRotationPolicy(aPolicy RotationPolicy) Option {
+	return func(l *Logger) {
+		l.policy = aPolicy
+	}
+}
+
+// WithRotateEvery sets interval for time based rotation. Boundaries are aligned
+// to midnight, the last period of a day ends at next midnight when interval
+// doesn't divide a day. Intervals longer than a day are counted from Monday
+// 2001-01-01, so weekly rotation happens on Mondays (0 - no time based
+// rotation). Can be combined with WithMaxBytes.
+func WithRotateEvery(aInterval time.Duration) Option {
+	return func(l *Logger) {
+		l.rotateEvery = aInterval
+	}
+}
+
+// WithRotateAt shifts time based rotation boundaries by aOffset from midnight.
+// Enables daily rotation when rotation interval is not set.
+func WithRotateAt(aOffset time.Duration) Option {
+	return func(l *Logger) {
+		l.rotateAt = aOffset
+		if l.rotateEvery == 0 {
+			l.rotateEvery = 24 * time.Hour
+		}
+	}
+}
+
+// WithOversizedWrites sets how to handle writes which exceed size or lines
+// limits (RejectOversized by default)
+func WithOversizedWrites(aMode OversizeMode) Option {
+	return func(l *Logger) {
+		l.oversize = aMode
+	}
+}
+
+// UseLineBuffering allows to keep partial lines in buffer until new line
+// arrives, so log rotated at line boundaries only (disabled by default)
+var UseLineBuffering = func(l *Logger) {
+	l.lineBuffering = true
+}
+
 // WithMaxBackups sets the max count of backups to store (0 - no limit)
 func WithMaxBackups(aCount int) Option {
 	return func(l *Logger) {
@@ -36,8 +101,70 @@ func WithMaxBackups(aCount int) Option {
 
 // WithMaxAge sets the number of days to store backups (0 - no limit)
 func WithMaxAge(aDays int) Option {
+	return WithMaxAgeDuration(time.Duration(aDays) * 24 * time.Hour)
+}
+
+// WithMaxAgeDuration sets how long to store backups (0 - no limit). Expired
+// backups removed even when log is not rotated.
+func WithMaxAgeDuration(aAge time.Duration) Option {
+	return func(l *Logger) {
+		l.maxAge = aAge
+	}
+}
+
+// WithSweepInterval enables removing and compressing backups every aInterval
+// in addition to sweeping after rotation (0 - only after rotation)
+func WithSweepInterval(aInterval time.Duration) Option {
+	return func(l *Logger) {
+		l.sweepInterval = aInterval
+	}
+}
+
+// WithArchiver sets archiver called for every backup before sweeping
+// removes it. Backup compressed first when compression enabled.
+func WithArchiver(aArchiver Archiver) Option {
+	return func(l *Logger) {
+		l.archiver = aArchiver
+	}
+}
+
+// WithHooks sets callbacks on log file lifecycle events
+func WithHooks(aHooks Hooks) Option {
+	return func(l *Logger) {
+		l.hooks = aHooks
+	}
+}
+
+// WithRetentionPolicy sets custom policy which selects backups to remove.
+// Policy applied in addition to backups count and age limits.
+func WithRetentionPolicy(aPolicy RetentionPolicy) Option {
+	return func(l *Logger) {
+		l.retention = aPolicy
+	}
+}
+
+// WithMaxTotalBytes limits total size of log file and backups in bytes. Oldest
+// backups removed when limit exceeded, backups pending compression counted
+// with uncompressed size. (0 - no limit)
+func WithMaxTotalBytes(aSize uint64) Option {
+	return func(l *Logger) {
+		l.totalBytesLimit = aSize
+	}
+}
+
+// WithFreeSpaceWatermark enables protection of log file system. When free
+// space falls below aLow bytes, writes handled according to aMode until free
+// space reaches aHigh again, and oldest backups removed in background until
+// free space reaches aHigh bytes. Free space checked on write not often
+// than once a second. Transitions reported to error handler.
+func WithFreeSpaceWatermark(aLow, aHigh uint64, aMode DegradedMode) Option {
 	return func(l *Logger) {
-		l.backupsDaysLimit = aDays
+		l.lowWatermark = aLow
+		l.highWatermark = aHigh
+		if l.highWatermark < aLow {
+			l.highWatermark = aLow
+		}
+		l.degradedMode = aMode
 	}
 }
 
@@ -46,11 +173,124 @@ var UseCompression = func(l *Logger) {
 	l.compress = true
 }
 
+// WithCompressor enables compression of backups with aCompressor
+// (gzip with default level by UseCompression)
+func WithCompressor(aCompressor Compressor) Option {
+	return func(l *Logger) {
+		l.compress = true
+		l.compressor = aCompressor
+	}
+}
+
+// WithBackupNamer sets naming scheme of backups (TimeNamer with
+// 20060102150405.000 layout by default)
+func WithBackupNamer(aNamer BackupNamer) Option {
+	return func(l *Logger) {
+		l.namer = aNamer
+	}
+}
+
+// WithBackupDir sets directory for backups (directory of log file by default).
+// Relative aDir is resolved against directory of log file. Backup directory
+// should be on the same file system as log file.
+func WithBackupDir(aDir string) Option {
+	return func(l *Logger) {
+		l.backupDir = aDir
+	}
+}
+
+// WithBackupPartitions places backups into subdirectories of backup directory
+// named by rotation time formatted with aLayout (e.g. "2006/01/02"). Empty
+// subdirectories removed with old backups.
+func WithBackupPartitions(aLayout string) Option {
+	return func(l *Logger) {
+		l.partitions = aLayout
+	}
+}
+
+// WithCurrentSymlink maintains symlink aPath pointing to active log file.
+// Symlink updated atomically each time new log file opened and removed on
+// Close.
+func WithCurrentSymlink(aPath string) Option {
+	return func(l *Logger) {
+		l.symlink = aPath
+	}
+}
+
 // UseLocaltime allows use local time for timestamps (UTC by default)
 var UseLocaltime = func(l *Logger) {
 	l.localtime = true
 }
 
+// WithReopenSignal installs handler which reopens log file on aSignal
+// (e.g. syscall.SIGHUP after external logrotate moved the file). Handler
+// installed by New, stopped by Close and installed again when closed logger
+// is written.
+func WithReopenSignal(aSignal os.Signal) Option {
+	return func(l *Logger) {
+		l.reopenSignal = aSignal
+	}
+}
+
+// WithFileCheck enables check on write that log file was not moved or deleted.
+// Log file reopened when it happens. Check performed not often than aInterval
+// (0 - on every write)
+func WithFileCheck(aInterval time.Duration) Option {
+	return func(l *Logger) {
+		l.checkFile = true
+		l.checkInterval = aInterval
+	}
+}
+
+// WithAsyncBuffer enables asynchronous writes. Data buffered in memory up to aSize
+// bytes and written to log file by background goroutine. Errors of background
+// writes reported to error handler. (0 - synchronous writes)
+func WithAsyncBuffer(aSize int) Option {
+	return func(l *Logger) {
+		l.asyncBuffer = aSize
+	}
+}
+
+// WithOverflowPolicy sets behavior of asynchronous writes when buffer is full
+// (BlockOnOverflow by default)
+func WithOverflowPolicy(aPolicy OverflowPolicy) Option {
+	return func(l *Logger) {
+		l.overflow = aPolicy
+	}
+}
+
+// WithWriteBuffer enables in-process buffering of writes with buffer of aSize
+// bytes. Buffered data written by Flush, Sync, rotation and Close.
+// (0 - no buffering)
+func WithWriteBuffer(aSize int) Option {
+	return func(l *Logger) {
+		l.writeBuffer = aSize
+	}
+}
+
+// WithSyncPolicy sets when written data synced to disk (SyncNever by default)
+func WithSyncPolicy(aPolicy SyncPolicy) Option {
+	return func(l *Logger) {
+		l.syncPolicy = aPolicy
+	}
+}
+
+// UseProcessLock allows several processes to write the same log file. Rotation
+// and sweeping serialized between processes by lock on sidecar files, and size
+// of log file re-read before each write. Buffered data written on each write.
+// (disabled by default)
+var UseProcessLock = func(l *Logger) {
+	l.processLock = true
+}
+
+// UseRecovery allows to check backups for leftovers of previous crash when log
+// file opened first time. Found issues reported to error handler. (disabled by
+// default, check performed on first sweeping anyway and on first open with
+// compression)
+var UseRecovery = func(l *Logger) {
+	l.recovery = true
+}
+
 // WithErrorHandler allows to set error handler for logger
 func WithErrorHandler(eh ErrHandler) Option {
 	return func(l *Logger) {
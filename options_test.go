@@ -1,7 +1,10 @@
 package rollinglog
 
 import (
+	"compress/gzip"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -10,6 +13,11 @@ func TestOptions(t *testing.T) {
 	l := New(WithLogFile("somelog.log"))
 
 	assert.Equal(t, "somelog.log", l.filename)
+	assert.Equal(t, "", l.pattern)
+
+	WithLogFile("somelog-%Y.log")(l)
+	assert.Equal(t, "somelog-%Y.log", l.pattern)
+	WithLogFile("somelog.log")(l)
 
 	UseCompression(l)
 	assert.True(t, l.compress)
@@ -18,15 +26,102 @@ func TestOptions(t *testing.T) {
 	assert.Equal(t, uint64(1000), l.sizeLimit)
 
 	WithMaxAge(5)(l)
-	assert.Equal(t, 5, l.backupsDaysLimit)
+	assert.Equal(t, 5*24*time.Hour, l.maxAge)
+
+	WithMaxAgeDuration(90 * time.Minute)(l)
+	assert.Equal(t, 90*time.Minute, l.maxAge)
+
+	WithSweepInterval(time.Minute)(l)
+	assert.Equal(t, time.Minute, l.sweepInterval)
+
+	WithArchiver(DirArchiver("archive"))(l)
+	assert.NotNil(t, l.archiver)
+
+	WithHooks(Hooks{OnOpen: func(OpenEvent) {}})(l)
+	assert.NotNil(t, l.hooks.OnOpen)
 
 	WithMaxBackups(2)(l)
 	assert.Equal(t, 2, l.backupsCountLimit)
 
+	WithMaxTotalBytes(1 << 20)(l)
+	assert.Equal(t, uint64(1<<20), l.totalBytesLimit)
+
+	WithFreeSpaceWatermark(100, 50, WriteStderr)(l)
+	assert.Equal(t, uint64(100), l.lowWatermark)
+	assert.Equal(t, uint64(100), l.highWatermark)
+	assert.Equal(t, WriteStderr, l.degradedMode)
+
+	WithRetentionPolicy(GFSPolicy(RetentionTier{Age: time.Hour}))(l)
+	assert.NotNil(t, l.retention)
+
+	c := GzipCompressor(gzip.BestSpeed)
+	WithCompressor(c)(l)
+	assert.True(t, l.compress)
+	assert.Equal(t, c, l.compressor)
+	assert.Equal(t, c, l.compression())
+
+	n := NumberedNamer()
+	WithBackupNamer(n)(l)
+	assert.Equal(t, n, l.backupNamer())
+
+	WithBackupDir("archive")(l)
+	assert.Equal(t, "archive", l.backupDir)
+
+	WithBackupPartitions("2006/01")(l)
+	assert.Equal(t, "2006/01", l.partitions)
+
+	WithCurrentSymlink("current.log")(l)
+	assert.Equal(t, "current.log", l.symlink)
+
 	UseLocaltime(l)
 	assert.True(t, l.localtime)
 
+	WithRotateAt(3 * time.Hour)(l)
+	assert.Equal(t, 3*time.Hour, l.rotateAt)
+	assert.Equal(t, 24*time.Hour, l.rotateEvery)
+
+	WithRotateEvery(time.Hour)(l)
+	assert.Equal(t, time.Hour, l.rotateEvery)
+
+	WithReopenSignal(os.Interrupt)(l)
+	assert.Equal(t, os.Interrupt, l.reopenSignal)
+
+	WithFileCheck(time.Second)(l)
+	assert.True(t, l.checkFile)
+	assert.Equal(t, time.Second, l.checkInterval)
+
+	WithMaxLines(100)(l)
+	assert.Equal(t, uint64(100), l.linesLimit)
+
+	WithOversizedWrites(SplitOversized)(l)
+	assert.Equal(t, SplitOversized, l.oversize)
+
+	UseLineBuffering(l)
+	assert.True(t, l.lineBuffering)
+
+	WithAsyncBuffer(1024)(l)
+	assert.Equal(t, 1024, l.asyncBuffer)
+
+	WithOverflowPolicy(DropOldest)(l)
+	assert.Equal(t, DropOldest, l.overflow)
+
+	WithWriteBuffer(4096)(l)
+	assert.Equal(t, 4096, l.writeBuffer)
+
+	WithSyncPolicy(SyncEveryBytes(100))(l)
+	assert.Equal(t, SyncEveryBytes(100), l.syncPolicy)
+
+	UseProcessLock(l)
+	assert.True(t, l.processLock)
+
+	UseRecovery(l)
+	assert.True(t, l.recovery)
+
+	p := SizePolicy(10)
+	WithRotationPolicy(p)(l)
+	assert.NotNil(t, l.policy)
+
 	Options(WithMaxBackups(0), WithMaxAge(20))(l)
 	assert.Equal(t, 0, l.backupsCountLimit)
-	assert.Equal(t, 20, l.backupsDaysLimit)
+	assert.Equal(t, 20*24*time.Hour, l.maxAge)
 }
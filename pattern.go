@@ -0,0 +1,260 @@
+package rollinglog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// directiveRe matches strftime-like directives supported in log file name
+var directiveRe = regexp.MustCompile(`%[YmdHMS%]`)
+
+// isPattern checks aFilename contains time directives
+func isPattern(aFilename string) bool {
+	return directiveRe.MatchString(aFilename)
+}
+
+// renderPattern replaces time directives in aPattern with values of aTime
+func renderPattern(aPattern string, aTime time.Time) string {
+	return directiveRe.ReplaceAllStringFunc(aPattern, func(aDirective string) string {
+		switch aDirective[1] {
+		case 'Y':
+			return fmt.Sprintf("%04d", aTime.Year())
+		case 'm':
+			return fmt.Sprintf("%02d", aTime.Month())
+		case 'd':
+			return fmt.Sprintf("%02d", aTime.Day())
+		case 'H':
+			return fmt.Sprintf("%02d", aTime.Hour())
+		case 'M':
+			return fmt.Sprintf("%02d", aTime.Minute())
+		case 'S':
+			return fmt.Sprintf("%02d", aTime.Second())
+		}
+		return "%"
+	})
+}
+
+// patternExpr returns regular expression matching names rendered from
+// aPattern. Values of time directives captured by groups named by directive.
+func patternExpr(aPattern string) string {
+	expr := ""
+	last := 0
+	for _, loc := range directiveRe.FindAllStringIndex(aPattern, -1) {
+		expr += regexp.QuoteMeta(aPattern[last:loc[0]])
+		last = loc[1]
+
+		switch d := aPattern[loc[0]+1]; d {
+		case 'Y':
+			expr += `(?P<Y>[0-9]{4})`
+		case '%':
+			expr += `%`
+		default:
+			expr += `(?P<` + string(d) + `>[0-9]{2})`
+		}
+	}
+
+	return expr + regexp.QuoteMeta(aPattern[last:])
+}
+
+// periodEnd returns end of period of log file aName matched by aRe. Period
+// is defined by the least time directive of pattern.
+func periodEnd(aRe *regexp.Regexp, aName string, aLocation *time.Location) time.Time {
+	m := aRe.FindStringSubmatch(aName)
+	if m == nil {
+		return time.Time{}
+	}
+
+	// Year, month, day, hour, minute and second
+	fields := []int{0, 1, 1, 0, 0, 0}
+	least := -1
+	for i, group := range aRe.SubexpNames() {
+		f := strings.Index("YmdHMS", group)
+		if group == "" || f < 0 {
+			continue
+		}
+
+		fields[f], _ = strconv.Atoi(m[i])
+		if f > least {
+			least = f
+		}
+	}
+
+	start := time.Date(fields[0], time.Month(fields[1]), fields[2], fields[3], fields[4], fields[5], 0, aLocation)
+
+	switch least {
+	case 0:
+		return start.AddDate(1, 0, 0)
+	case 1:
+		return start.AddDate(0, 1, 0)
+	case 2:
+		return start.AddDate(0, 0, 1)
+	case 3:
+		return start.Add(time.Hour)
+	case 4:
+		return start.Add(time.Minute)
+	case 5:
+		return start.Add(time.Second)
+	}
+	return time.Time{}
+}
+
+// patternNamer recognises log files of previous periods and their backups
+// as backups of log file with time pattern in name
+type patternNamer struct {
+	BackupNamer
+	// file matches whole name of log file
+	file *regexp.Regexp
+	// stem matches name of log file without extension inside backup name
+	stem     *regexp.Regexp
+	ext      string
+	location *time.Location
+}
+
+func newPatternNamer(aPattern string, aNamer BackupNamer, aLocation *time.Location) patternNamer {
+	stem, ext := splitExt(aPattern)
+	if isPattern(ext) {
+		stem, ext = aPattern, ""
+	}
+
+	return patternNamer{
+		BackupNamer: aNamer,
+		file:        regexp.MustCompile("^" + patternExpr(aPattern) + "$"),
+		stem:        regexp.MustCompile(patternExpr(stem)),
+		ext:         ext,
+		location:    aLocation,
+	}
+}
+
+// Parse returns end of period for log files of previous periods, so they
+// are newer than backups rotated during the period
+func (n patternNamer) Parse(aLogName, aBackupName string) (time.Time, int, error) {
+	if aBackupName == aLogName {
+		return time.Time{}, 0, errors.New("active log file")
+	}
+
+	if n.file.MatchString(aBackupName) {
+		return periodEnd(n.file, aBackupName, n.location), 0, nil
+	}
+
+	for _, loc := range n.stem.FindAllStringIndex(aBackupName, -1) {
+		logName := aBackupName[loc[0]:loc[1]] + n.ext
+		if ts, seq, err := n.BackupNamer.Parse(logName, aBackupName); err == nil {
+			return ts, seq, nil
+		}
+	}
+
+	return time.Time{}, 0, errors.New("mismatch pattern")
+}
+
+// listingNamer returns namer which recognises backups of log file
+func (l *Logger) listingNamer() BackupNamer {
+	if l.pattern == "" {
+		return l.parsingNamer()
+	}
+
+	location := time.UTC
+	if l.localtime {
+		location = time.Local
+	}
+	return newPatternNamer(filepath.Base(l.pattern), l.parsingNamer(), location)
+}
+
+// logFilename returns name of active log file. Used by sweeping which runs
+// without logger lock while log file switched.
+func (l *Logger) logFilename() string {
+	l.nameLock.RLock()
+	defer l.nameLock.RUnlock()
+
+	return l.filename
+}
+
+// isActive checks aPath is active log file. Log file switched holding
+// backupLock, so result stays valid while it held.
+func (l *Logger) isActive(aPath string) bool {
+	return filepath.Clean(aPath) == filepath.Clean(l.logFilename())
+}
+
+// checkPattern rejects time directives in directory of log file: backups are
+// looked for in directory of active log file only
+func (l *Logger) checkPattern() error {
+	if l.pattern != "" && isPattern(filepath.Dir(l.pattern)) {
+		return errors.Errorf("time directives are allowed in log file name only: %s", l.pattern)
+	}
+	return nil
+}
+
+// lockName returns name of sidecar lock file with aSuffix. Lock is shared by
+// all files of time pattern.
+func (l *Logger) lockName(aSuffix string) string {
+	if l.pattern == "" {
+		return l.filename + aSuffix
+	}
+	return l.pattern + aSuffix
+}
+
+// followPattern switches to new log file when rendered time pattern changed.
+// Previous log file moved to backup directory if it is set.
+func (l *Logger) followPattern() error {
+	if l.pattern == "" {
+		return nil
+	}
+
+	now := l.now()
+	name := renderPattern(l.pattern, now)
+	if name == l.filename {
+		return nil
+	}
+
+	state := l.state
+	opened := l.file != nil
+	if opened {
+		if err := l.close(); err != nil {
+			return errors.Wrapf(err, "can't close %s for switch", l.filename)
+		}
+	}
+
+	// Sweeping listed backups with previous name could take new log file
+	// for backup, so switch between its steps only
+	prev := l.filename
+	l.backupLock.Lock()
+	l.nameLock.Lock()
+	l.filename = name
+	l.nameLock.Unlock()
+	l.backupLock.Unlock()
+
+	if !opened {
+		return nil
+	}
+
+	start := time.Now()
+	backup := prev
+	if l.backupDir != "" || l.partitions != "" {
+		dir := l.backupDirFor(now)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return errors.Wrapf(err, "can't make backups directory %s", dir)
+		}
+		backup = filepath.Join(dir, filepath.Base(prev))
+		err := os.Rename(prev, backup)
+		if err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "can't move %s to backups", prev)
+		}
+	}
+
+	l.hooks.rotate(RotateEvent{
+		Filename: prev,
+		Backup:   backup,
+		Reason:   RotateTime,
+		State:    state,
+		Duration: time.Since(start),
+	})
+
+	l.sweepPending = true
+	return nil
+}
@@ -0,0 +1,141 @@
+package rollinglog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderPattern(t *testing.T) {
+	ts := time.Date(2026, 1, 6, 7, 8, 9, 0, time.UTC)
+
+	assert.True(t, isPattern("app-%Y.log"))
+	assert.False(t, isPattern("app-100%.log"))
+
+	assert.Equal(t, "app-2026-01-06.log", renderPattern("app-%Y-%m-%d.log", ts))
+	assert.Equal(t, "app-070809-%d.log", renderPattern("app-%H%M%S-%%d.log", ts))
+	assert.Equal(t, "app-%x.log", renderPattern("app-%x.log", ts))
+}
+
+func TestPatternNamer(t *testing.T) {
+	n := newPatternNamer("app-%Y-%m-%d.log", defaultNamer, time.UTC)
+
+	tests := []struct {
+		filename string
+		want     time.Time
+		wantErr  bool
+	}{
+		{"app-2026-10-15.log", time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC), false},
+		{"app-2026-10-15.20261015120000.000.log", time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC), false},
+		{"app-2026-10-15.20261015120000.000-1.log", time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC), false},
+		{"app-2026-10-16.log", time.Time{}, true},
+		{"app-2026-10.log", time.Time{}, true},
+		{"app-2026-10-15.txt", time.Time{}, true},
+		{"other-2026-10-15.log", time.Time{}, true},
+	}
+
+	for _, test := range tests {
+		ts, _, err := n.Parse("app-2026-10-16.log", test.filename)
+		assert.Equal(t, test.wantErr, err != nil, test.filename)
+		assert.Equal(t, test.want, ts, test.filename)
+	}
+
+	re := regexp.MustCompile(patternExpr("app-%Y%m.log"))
+	assert.Equal(t, time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC), periodEnd(re, "app-202612.log", time.UTC))
+
+	re = regexp.MustCompile(patternExpr("app-%Y%m%d-%H.log"))
+	assert.Equal(t, time.Date(2026, 12, 1, 16, 0, 0, 0, time.UTC), periodEnd(re, "app-20261201-15.log", time.UTC))
+}
+
+func TestPatternFiles(t *testing.T) {
+	dir := makeTempDir("TestPatternFiles", t)
+	defer os.RemoveAll(dir)
+
+	now := time.Date(2026, 10, 13, 12, 0, 0, 0, time.UTC)
+	currentTime = func() time.Time { return now }
+	defer func() { currentTime = time.Now }()
+
+	l := New(WithLogFile(filepath.Join(dir, "app-%Y-%m-%d.log")), WithMaxBytes(10),
+		WithMaxBackups(3), UseCompression)
+
+	for i := 3; i <= 6; i++ {
+		now = now.Add(24 * time.Hour)
+
+		// Second write rotates by size
+		for j := 0; j < 2; j++ {
+			_, err := l.Write([]byte(fmt.Sprintf("%d%d2345678", i, j)))
+			require.NoError(t, err)
+
+			// Wait for sweeping
+			<-time.After(time.Millisecond * 10)
+		}
+	}
+	require.NoError(t, l.Close())
+
+	existsWithContent(filepath.Join(dir, "app-2026-10-17.log"), []byte("612345678"), t)
+
+	backups, err := l.backups()
+	require.NoError(t, err)
+
+	names := []string{}
+	for _, b := range backups {
+		names = append(names, b.name)
+	}
+
+	assert.Equal(t, []string{
+		"app-2026-10-17.20261017120000.000.log.gz",
+		"app-2026-10-16.log.gz",
+		"app-2026-10-16.20261016120000.000.log.gz",
+	}, names)
+}
+
+func TestPatternInDirectory(t *testing.T) {
+	dir := makeTempDir("TestPatternInDirectory", t)
+	defer os.RemoveAll(dir)
+
+	l := New(WithLogFile(filepath.Join(dir, "%Y-%m-%d", "app.log")), WithMaxBackups(1), UseProcessLock)
+	defer l.Close()
+
+	_, err := l.Write([]byte("1\n"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "allowed in log file name only")
+
+	require.Error(t, l.Rotate())
+	require.Error(t, l.Reopen())
+
+	// Neither log file nor lock created
+	count, err := getFilesInDir(t, dir)
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+}
+
+func TestPatternBackupDir(t *testing.T) {
+	dir := makeTempDir("TestPatternBackupDir", t)
+	defer os.RemoveAll(dir)
+
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	currentTime = func() time.Time { return now }
+	defer func() { currentTime = time.Now }()
+
+	l := New(WithLogFile(filepath.Join(dir, "app-%Y-%m-%d.log")), WithBackupDir("archive"))
+
+	_, err := l.Write([]byte("1\n"))
+	require.NoError(t, err)
+
+	now = now.Add(24 * time.Hour)
+	_, err = l.Write([]byte("2\n"))
+	require.NoError(t, err)
+	require.NoError(t, l.Close())
+
+	existsWithContent(filepath.Join(dir, "archive", "app-2026-10-15.log"), []byte("1\n"), t)
+	existsWithContent(filepath.Join(dir, "app-2026-10-16.log"), []byte("2\n"), t)
+
+	_, err = os.Stat(filepath.Join(dir, "app-2026-10-15.log"))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,150 @@
+package rollinglog
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// FileState describes current log file for rotation policy
+type FileState struct {
+	// Size of log file in bytes
+	Size uint64
+	// Lines is count of new lines in log file
+	Lines uint64
+	// Opened is time when logger opened log file
+	Opened time.Time
+	// FirstWrite is time of first write after log file opened (zero when nothing written)
+	FirstWrite time.Time
+}
+
+// written updates state after aData written to log file
+func (s *FileState) written(aData []byte) {
+	if len(aData) == 0 {
+		return
+	}
+
+	if s.FirstWrite.IsZero() {
+		s.FirstWrite = currentTime()
+	}
+
+	s.Size += uint64(len(aData))
+	s.Lines += newLines(aData)
+}
+
+// RotationPolicy decides log file should be rotated before aPending data written
+type RotationPolicy interface {
+	ShouldRotate(aState FileState, aPending []byte) bool
+}
+
+// RotationPolicyFunc is an adapter to allow the use of ordinary functions as RotationPolicy
+type RotationPolicyFunc func(aState FileState, aPending []byte) bool
+
+// ShouldRotate calls f(aState, aPending)
+func (f RotationPolicyFunc) ShouldRotate(aState FileState, aPending []byte) bool {
+	return f(aState, aPending)
+}
+
+// SizePolicy rotates log file when its size would exceed aLimit bytes
+func SizePolicy(aLimit uint64) RotationPolicy {
+	return RotationPolicyFunc(func(aState FileState, aPending []byte) bool {
+		return sizeExceeded(aState.Size+uint64(len(aPending)), aLimit)
+	})
+}
+
+// LinesPolicy rotates log file when count of lines would exceed aLimit
+func LinesPolicy(aLimit uint64) RotationPolicy {
+	return RotationPolicyFunc(func(aState FileState, aPending []byte) bool {
+		return sizeExceeded(aState.Lines+newLines(aPending), aLimit)
+	})
+}
+
+// AgePolicy rotates log file when first write to it was more than aAge ago
+func AgePolicy(aAge time.Duration) RotationPolicy {
+	return RotationPolicyFunc(func(aState FileState, aPending []byte) bool {
+		if aState.FirstWrite.IsZero() {
+			return false
+		}
+		return currentTime().Sub(aState.FirstWrite) >= aAge
+	})
+}
+
+// Any rotates log file when any of aPolicies requires rotation
+func Any(aPolicies ...RotationPolicy) RotationPolicy {
+	return RotationPolicyFunc(func(aState FileState, aPending []byte) bool {
+		for _, p := range aPolicies {
+			if p.ShouldRotate(aState, aPending) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// All rotates log file when all of aPolicies require rotation
+func All(aPolicies ...RotationPolicy) RotationPolicy {
+	return RotationPolicyFunc(func(aState FileState, aPending []byte) bool {
+		for _, p := range aPolicies {
+			if !p.ShouldRotate(aState, aPending) {
+				return false
+			}
+		}
+		return len(aPolicies) > 0
+	})
+}
+
+// policyRotation checks custom rotation policy. Empty files never rotated.
+func (l *Logger) policyRotation(aState FileState, aPending []byte) bool {
+	if l.policy == nil || aState.Size == 0 {
+		return false
+	}
+
+	return l.policy.ShouldRotate(aState, aPending)
+}
+
+// rotationReason checks size and lines limits and custom rotation policy
+// before aPending written. Returns false when rotation is not required.
+func (l *Logger) rotationReason(aState FileState, aPending []byte) (RotateReason, bool) {
+	switch {
+	case sizeExceeded(aState.Size+uint64(len(aPending)), l.sizeLimit):
+		return RotateSize, true
+	case sizeExceeded(aState.Lines+newLines(aPending), l.linesLimit):
+		return RotateLines, true
+	case l.policyRotation(aState, aPending):
+		return RotatePolicy, true
+	}
+
+	return RotateSize, false
+}
+
+// newLines returns count of new lines in aData
+func newLines(aData []byte) uint64 {
+	return uint64(bytes.Count(aData, []byte{'\n'}))
+}
+
+// countLines returns count of new lines in aFilename
+func countLines(aFilename string) (uint64, error) {
+	f, err := os.Open(aFilename)
+	if err != nil {
+		return 0, errors.Wrapf(err, "can't open %s for count lines", aFilename)
+	}
+	defer f.Close()
+
+	var count uint64
+	buf := make([]byte, 32*1024)
+
+	for {
+		n, err := f.Read(buf)
+		count += newLines(buf[:n])
+
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return 0, errors.Wrapf(err, "can't count lines in %s", aFilename)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package rollinglog
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicies(t *testing.T) {
+	now := time.Now()
+	currentTime = func() time.Time { return now }
+	defer func() { currentTime = time.Now }()
+
+	state := FileState{Size: 10, Lines: 2, Opened: now.Add(-2 * time.Hour), FirstWrite: now.Add(-time.Hour)}
+
+	assert.False(t, SizePolicy(15).ShouldRotate(state, []byte("12345")))
+	assert.True(t, SizePolicy(15).ShouldRotate(state, []byte("123456")))
+	assert.False(t, SizePolicy(0).ShouldRotate(state, []byte("123456")))
+
+	assert.False(t, LinesPolicy(3).ShouldRotate(state, []byte("1\n2")))
+	assert.True(t, LinesPolicy(3).ShouldRotate(state, []byte("1\n2\n")))
+
+	assert.True(t, AgePolicy(time.Hour).ShouldRotate(state, nil))
+	assert.False(t, AgePolicy(2*time.Hour).ShouldRotate(state, nil))
+	assert.False(t, AgePolicy(time.Minute).ShouldRotate(FileState{}, nil))
+
+	assert.True(t, Any(SizePolicy(100), AgePolicy(time.Hour)).ShouldRotate(state, nil))
+	assert.False(t, Any(SizePolicy(100), AgePolicy(2*time.Hour)).ShouldRotate(state, nil))
+	assert.False(t, Any().ShouldRotate(state, nil))
+
+	assert.True(t, All(SizePolicy(5), AgePolicy(time.Hour)).ShouldRotate(state, nil))
+	assert.False(t, All(SizePolicy(100), AgePolicy(time.Hour)).ShouldRotate(state, nil))
+	assert.False(t, All().ShouldRotate(state, nil))
+}
+
+func TestFileStateWritten(t *testing.T) {
+	s := FileState{}
+	s.written(nil)
+	assert.True(t, s.FirstWrite.IsZero())
+
+	s.written([]byte("1\n2\n3"))
+	assert.False(t, s.FirstWrite.IsZero())
+	assert.Equal(t, uint64(5), s.Size)
+	assert.Equal(t, uint64(2), s.Lines)
+}
+
+func TestRotateByPolicy(t *testing.T) {
+	dir := makeTempDir("TestRotateByPolicy", t)
+	defer os.RemoveAll(dir)
+
+	var states []FileState
+	policy := RotationPolicyFunc(func(aState FileState, aPending []byte) bool {
+		states = append(states, aState)
+		return aState.Lines >= 2
+	})
+
+	lf := logFile(dir)
+	l := New(WithLogFile(lf), WithRotationPolicy(policy))
+
+	b := []byte("12345\n")
+	for i := 0; i < 3; i++ {
+		_, err := l.Write(b)
+		require.NoError(t, err)
+	}
+
+	// Empty file is not checked
+	require.Len(t, states, 2)
+	assert.Equal(t, uint64(1), states[0].Lines)
+	assert.False(t, states[0].Opened.IsZero())
+	assert.False(t, states[0].FirstWrite.IsZero())
+	assert.Equal(t, uint64(12), states[1].Size)
+
+	existsWithContent(lf, b, t)
+	count, err := getFilesInDir(t, dir)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	require.NoError(t, l.Close())
+
+	// Lines restored on open
+	require.NoError(t, ioutil.WriteFile(lf, []byte("1\n2\n"), 0644))
+	states = nil
+
+	l = New(WithLogFile(lf), WithRotationPolicy(policy))
+	defer l.Close()
+
+	<-time.After(time.Millisecond * 10)
+
+	_, err = l.Write(b)
+	require.NoError(t, err)
+	require.Len(t, states, 1)
+	assert.Equal(t, uint64(2), states[0].Lines)
+
+	existsWithContent(lf, b, t)
+	count, err = getFilesInDir(t, dir)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+}
+
+func TestCountLines(t *testing.T) {
+	dir := makeTempDir("TestCountLines", t)
+	defer os.RemoveAll(dir)
+
+	lf := logFile(dir)
+	require.NoError(t, ioutil.WriteFile(lf, []byte("1\n2\n\n3"), 0644))
+
+	count, err := countLines(lf)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), count)
+
+	_, err = countLines(lf + ".none")
+	assert.Error(t, err)
+}
@@ -0,0 +1,217 @@
+package rollinglog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+)
+
+// RecoveryReport describes leftovers of previous crash found in backups
+type RecoveryReport struct {
+	// Removed files: orphaned temporary files, empty compressed backups and
+	// duplicates of backups stored both compressed and uncompressed
+	Removed []string
+	// Corrupted compressed backups which have no uncompressed copy. Such
+	// backups are kept.
+	Corrupted []string
+	// Unparsable files named as backups but with invalid timestamp. Such
+	// files are kept.
+	Unparsable []string
+}
+
+// Empty reports nothing found by recovery
+func (r RecoveryReport) Empty() bool {
+	return len(r.Removed) == 0 && len(r.Corrupted) == 0 && len(r.Unparsable) == 0
+}
+
+func (r RecoveryReport) String() string {
+	return fmt.Sprintf("removed %v, corrupted %v, unparsable %v", r.Removed, r.Corrupted, r.Unparsable)
+}
+
+// Recover checks backups for leftovers of previous crash and fixes them.
+// Same check performed on first sweeping automatically.
+func (l *Logger) Recover() (report RecoveryReport, err error) {
+	err = l.withSweepLock(func() (e error) {
+		report, e = l.recover()
+		return e
+	})
+
+	atomic.StoreInt32(&l.recovered, 1)
+	return report, err
+}
+
+// recoverOnce runs recovery if it was not done yet and reports result to
+// error handler. Should be called holding sweeping lock.
+func (l *Logger) recoverOnce() {
+	if !atomic.CompareAndSwapInt32(&l.recovered, 0, 1) {
+		return
+	}
+
+	report, err := l.recover()
+	if err != nil {
+		l.errHandler(err)
+	}
+
+	if !report.Empty() {
+		l.errHandler(errors.Errorf("recovered backups of %s: %s", l.logFilename(), report))
+	}
+}
+
+// startupRecovery runs recovery on first open if enabled or compression used,
+// so leftovers of interrupted compression don't wait for first sweeping
+func (l *Logger) startupRecovery() {
+	if !(l.recovery || l.compress) || atomic.LoadInt32(&l.recovered) == 1 {
+		return
+	}
+
+	err := l.withSweepLock(func() error {
+		l.recoverOnce()
+		return nil
+	})
+
+	if err != nil {
+		l.errHandler(err)
+	}
+}
+
+func (l *Logger) recover() (report RecoveryReport, err error) {
+	l.backupLock.Lock()
+	defer l.backupLock.Unlock()
+
+	dir := l.backupsRoot()
+	files, err := listFiles(dir, l.partitionDepth())
+	if os.IsNotExist(errors.Cause(err)) {
+		return report, nil
+	}
+	if err != nil {
+		return report, err
+	}
+
+	logFilename := l.logFilename()
+	_, base := filepath.Split(logFilename)
+	prefix, suffix := splitFilename(logFilename)
+	cSuffixes := compressedSuffixes(l.compression().Suffix())
+	namer := l.listingNamer()
+
+	exists := map[string]bool{}
+	for _, f := range files {
+		exists[f.path] = true
+	}
+
+	errs := new(multierror.Error)
+	remove := func(aName string) {
+		if err := os.Remove(filepath.Join(dir, aName)); err != nil && !os.IsNotExist(err) {
+			errs = multierror.Append(errs, err)
+			return
+		}
+		removeEmptyDirs(filepath.Dir(filepath.Join(dir, aName)), dir)
+		exists[aName] = false
+		report.Removed = append(report.Removed, aName)
+	}
+
+	isBackup := func(aName, aSuffix string) bool {
+		if !strings.HasSuffix(aName, aSuffix) {
+			return false
+		}
+		_, _, err := namer.Parse(base, strings.TrimSuffix(filepath.Base(aName), aSuffix))
+		return err == nil
+	}
+
+	// Files named as backups with suffix, but without valid timestamp
+	unparsable := func(aName, aSuffix string) bool {
+		aName = filepath.Base(aName)
+		return strings.HasPrefix(aName, prefix) && strings.HasSuffix(aName, aSuffix) &&
+			len(aName) > len(prefix)+len(aSuffix)
+	}
+
+	for _, f := range files {
+		name := f.path
+		if !exists[name] || name == base+lockSuffix || name == base+sweepLockSuffix {
+			continue
+		}
+
+		if isBackup(name, "") {
+			continue
+		}
+
+		known := false
+		for _, cs := range cSuffixes {
+			if isBackup(name, cs+tempSuffix) {
+				remove(name)
+				known = true
+				break
+			}
+
+			if !isBackup(name, cs) {
+				continue
+			}
+
+			known = true
+			path := filepath.Join(dir, name)
+			source := strings.TrimSuffix(name, cs)
+
+			switch {
+			case exists[source]:
+				// Keep valid copy
+				if l.verifyBackup(path, cs) == nil {
+					remove(source)
+				} else {
+					remove(name)
+				}
+			case f.info.Size() == 0:
+				remove(name)
+			case l.verifyBackup(path, cs) != nil:
+				report.Corrupted = append(report.Corrupted, name)
+			}
+			break
+		}
+
+		if known {
+			continue
+		}
+
+		if unparsable(name, suffix) {
+			report.Unparsable = append(report.Unparsable, name)
+			continue
+		}
+
+		for _, cs := range cSuffixes {
+			if unparsable(name, suffix+cs) {
+				report.Unparsable = append(report.Unparsable, name)
+				break
+			}
+		}
+	}
+
+	return report, errs.ErrorOrNil()
+}
+
+// verifyBackup checks integrity of compressed backup aPath if compressor
+// for aSuffix is able to do it
+func (l *Logger) verifyBackup(aPath, aSuffix string) error {
+	codec := registeredCompressor(aSuffix)
+	if c := l.compression(); c.Suffix() == aSuffix {
+		codec = c
+	}
+
+	v, ok := codec.(Verifier)
+	if !ok {
+		return nil
+	}
+
+	f, err := os.Open(aPath)
+	if err != nil {
+		return errors.Wrapf(err, "can't open %s for verify", aPath)
+	}
+	defer f.Close()
+
+	if err = v.Verify(f); err != nil {
+		return errors.Wrapf(err, "%s is corrupted", aPath)
+	}
+	return nil
+}
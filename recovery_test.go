@@ -0,0 +1,138 @@
+package rollinglog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func gzipData(t testing.TB, aData []byte) []byte {
+	buf := &bytes.Buffer{}
+	require.NoError(t, GzipCompressor(gzip.DefaultCompression).Compress(buf, bytes.NewReader(aData)))
+	return buf.Bytes()
+}
+
+func TestRecover(t *testing.T) {
+	dir := makeTempDir("TestRecover", t)
+	defer os.RemoveAll(dir)
+
+	lf := filepath.Join(dir, "foo.log")
+	valid := gzipData(t, []byte("data"))
+
+	files := map[string][]byte{
+		// Valid duplicate: plain copy removed
+		"foo.20140504144133.555.log":    []byte("data"),
+		"foo.20140504144133.555.log.gz": valid,
+		// Half-written duplicate: archive removed
+		"foo.20140504144233.555.log":    []byte("data"),
+		"foo.20140504144233.555.log.gz": valid[:len(valid)/2],
+		// Empty archive
+		"foo.20140504144333.555.log.gz": {},
+		// Corrupted without copy
+		"foo.20140504144433.555.log.gz": []byte("data"),
+		// Orphaned temporary file
+		"foo.20140504144533.555.log.gz.tmp": valid[:len(valid)/2],
+		// Valid backups
+		"foo.20140504144633.555.log":    []byte("data"),
+		"foo.20140504144733.555.log.gz": valid,
+		// Unparsable
+		"foo.2014050414.log":    []byte("data"),
+		"foo.2014050414.log.gz": valid,
+		// Not backups
+		"foo.log":      []byte("data"),
+		"foo.log.lock": {},
+		"bar.log.gz":   valid,
+	}
+
+	for name, data := range files {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), data, 0644))
+	}
+
+	l := New(WithLogFile(lf))
+	report, err := l.Recover()
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"foo.20140504144133.555.log",
+		"foo.20140504144233.555.log.gz",
+		"foo.20140504144333.555.log.gz",
+		"foo.20140504144533.555.log.gz.tmp",
+	}, report.Removed)
+	assert.Equal(t, []string{"foo.20140504144433.555.log.gz"}, report.Corrupted)
+	assert.Equal(t, []string{"foo.2014050414.log", "foo.2014050414.log.gz"}, report.Unparsable)
+	assert.False(t, report.Empty())
+
+	backups, err := filterBackups(dir, filepath.Base(lf), 0, defaultNamer)
+	require.NoError(t, err)
+	assert.Equal(t, 5, len(backups))
+
+	// Nothing to do second time
+	report, err = l.Recover()
+	require.NoError(t, err)
+	assert.Empty(t, report.Removed)
+	assert.Equal(t, 1, len(report.Corrupted))
+}
+
+func TestRecoverMissingDir(t *testing.T) {
+	l := New(WithLogFile(filepath.Join(".tests", "TestRecoverMissingDir", "foo.log")))
+
+	report, err := l.Recover()
+	require.NoError(t, err)
+	assert.True(t, report.Empty())
+}
+
+func TestStartupRecovery(t *testing.T) {
+	dir := makeTempDir("TestStartupRecovery", t)
+	defer os.RemoveAll(dir)
+
+	lf := logFile(dir)
+	prefix, suffix := splitFilename(lf)
+	orphan := filepath.Join(dir, prefix+"20140504144433.555"+suffix+compressSuffix+tempSuffix)
+	require.NoError(t, ioutil.WriteFile(orphan, []byte("partial"), 0644))
+
+	var errs []error
+	l := New(WithLogFile(lf), UseRecovery, WithErrorHandler(func(err error) {
+		errs = append(errs, err)
+	}))
+	defer l.Close()
+
+	_, err := l.Write([]byte("123456789"))
+	require.NoError(t, err)
+
+	_, err = os.Stat(orphan)
+	assert.True(t, os.IsNotExist(err), "orphan not removed")
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Error(), "recovered backups")
+
+	// Only once
+	require.NoError(t, l.Close())
+	_, err = l.Write([]byte("123456789"))
+	require.NoError(t, err)
+	assert.Len(t, errs, 1)
+}
+
+func TestStartupRecoveryCompression(t *testing.T) {
+	dir := makeTempDir("TestStartupRecoveryCompression", t)
+	defer os.RemoveAll(dir)
+
+	lf := logFile(dir)
+	prefix, suffix := splitFilename(lf)
+	orphan := filepath.Join(dir, prefix+"20140504144433.555"+suffix+compressSuffix+tempSuffix)
+	require.NoError(t, ioutil.WriteFile(orphan, []byte("partial"), 0644))
+
+	l := New(WithLogFile(lf), UseCompression, WithErrorHandler(func(err error) {}))
+	defer l.Close()
+
+	// Removed on open without waiting for rotation
+	_, err := l.Write([]byte("123456789"))
+	require.NoError(t, err)
+
+	_, err = os.Stat(orphan)
+	assert.True(t, os.IsNotExist(err), "orphan not removed")
+}
@@ -0,0 +1,126 @@
+package rollinglog
+
+import (
+	"sort"
+	"time"
+)
+
+// Backup describes backup for retention policy
+type Backup struct {
+	// Name of backup relative to backup directory
+	Name string
+	// Time of backup (rotation time or modification time when naming scheme
+	// has no time)
+	Time time.Time
+	// Size of backup in bytes
+	Size int64
+	// Compressed is true for compressed backup
+	Compressed bool
+}
+
+// RetentionPolicy selects backups to remove. aBackups sorted from newest to
+// oldest.
+type RetentionPolicy interface {
+	Expired(aNow time.Time, aBackups []Backup) []Backup
+}
+
+// RetentionPolicyFunc is an adapter to allow the use of ordinary functions as RetentionPolicy
+type RetentionPolicyFunc func(aNow time.Time, aBackups []Backup) []Backup
+
+// Expired calls f(aNow, aBackups)
+func (f RetentionPolicyFunc) Expired(aNow time.Time, aBackups []Backup) []Backup {
+	return f(aNow, aBackups)
+}
+
+// RetentionTier keeps one backup per Interval for backups younger than Age
+// (every backup with zero Interval)
+type RetentionTier struct {
+	Age      time.Duration
+	Interval time.Duration
+}
+
+// GFSPolicy is grandfather-father-son retention. Backup kept by the tier with
+// the least age it fits, the oldest backup of each interval kept. Backups older
+// than all tiers removed. E.g. every backup for 2 days, one per day for 30 days
+// and one per week for a year:
+//
+//	GFSPolicy(
+//		RetentionTier{Age: 48 * time.Hour},
+//		RetentionTier{Age: 30 * 24 * time.Hour, Interval: 24 * time.Hour},
+//		RetentionTier{Age: 365 * 24 * time.Hour, Interval: 7 * 24 * time.Hour},
+//	)
+func GFSPolicy(aTiers ...RetentionTier) RetentionPolicy {
+	tiers := append([]RetentionTier(nil), aTiers...)
+	sort.Slice(tiers, func(i, j int) bool {
+		return tiers[i].Age < tiers[j].Age
+	})
+
+	return RetentionPolicyFunc(func(aNow time.Time, aBackups []Backup) (expired []Backup) {
+		type slot struct {
+			tier   int
+			bucket time.Time
+		}
+		kept := map[slot]bool{}
+
+		// From oldest, so the oldest backup of interval kept
+		for i := len(aBackups) - 1; i >= 0; i-- {
+			b := aBackups[i]
+			age := aNow.Sub(b.Time)
+
+			tier := sort.Search(len(tiers), func(t int) bool {
+				return age < tiers[t].Age
+			})
+
+			switch {
+			case tier == len(tiers):
+				expired = append(expired, b)
+			case tiers[tier].Interval > 0:
+				s := slot{tier, b.Time.Truncate(tiers[tier].Interval)}
+				if kept[s] {
+					expired = append(expired, b)
+				}
+				kept[s] = true
+			}
+		}
+
+		return expired
+	})
+}
+
+// export returns description of backup for retention policy
+func (b backupInfo) export() Backup {
+	return Backup{
+		Name:       b.name,
+		Time:       b.timestamp,
+		Size:       b.size,
+		Compressed: b.compressed,
+	}
+}
+
+// applyRetention removes backups expired by retention policy from aBackups
+// and returns them separately
+func (l *Logger) applyRetention(aBackups []backupInfo) (rest, expired []backupInfo) {
+	if l.retention == nil {
+		return aBackups, nil
+	}
+
+	backups := make([]Backup, 0, len(aBackups))
+	for _, b := range aBackups {
+		backups = append(backups, b.export())
+	}
+
+	names := map[string]bool{}
+	for _, b := range l.retention.Expired(currentTime(), backups) {
+		names[b.Name] = true
+	}
+
+	for _, b := range aBackups {
+		if names[b.name] {
+			expired = append(expired, b)
+		} else {
+			rest = append(rest, b)
+		}
+	}
+
+	return rest, expired
+}
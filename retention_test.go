@@ -0,0 +1,111 @@
+package rollinglog
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGFSPolicy(t *testing.T) {
+	day := 24 * time.Hour
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+
+	policy := GFSPolicy(
+		RetentionTier{Age: 365 * day, Interval: 7 * day},
+		RetentionTier{Age: 2 * day},
+		RetentionTier{Age: 30 * day, Interval: day},
+	)
+
+	// Every 6 hours for 400 days, newest first
+	backups := []Backup{}
+	for ts := now.Add(-time.Hour); ts.After(now.Add(-400 * day)); ts = ts.Add(-6 * time.Hour) {
+		backups = append(backups, Backup{Name: ts.Format(backupTimeFormat), Time: ts})
+	}
+
+	expired := map[string]bool{}
+	for _, b := range policy.Expired(now, backups) {
+		expired[b.Name] = true
+	}
+
+	kept := []Backup{}
+	for _, b := range backups {
+		if !expired[b.Name] {
+			kept = append(kept, b)
+		}
+	}
+
+	days := map[time.Time]int{}
+	weeks := map[time.Time]int{}
+	recent := 0
+	for _, b := range kept {
+		age := now.Sub(b.Time)
+		require.True(t, age < 365*day, "backup older than year kept")
+
+		switch {
+		case age < 2*day:
+			recent++
+		case age < 30*day:
+			days[b.Time.Truncate(day)]++
+		default:
+			weeks[b.Time.Truncate(7*day)]++
+		}
+	}
+
+	assert.Equal(t, 8, recent, "every backup for 2 days")
+	for d, n := range days {
+		assert.Equal(t, 1, n, "one backup per day %v", d)
+	}
+	assert.True(t, len(days) >= 27)
+	for w, n := range weeks {
+		assert.Equal(t, 1, n, "one backup per week %v", w)
+	}
+	assert.True(t, len(weeks) >= 47)
+
+	// Kept backups stay kept as time goes
+	later := now.Add(6 * time.Hour)
+	for _, b := range policy.Expired(later, kept) {
+		assert.True(t, later.Sub(b.Time) >= 2*day, "backup %s removed", b.Name)
+	}
+}
+
+func TestRetentionPolicy(t *testing.T) {
+	dir := makeTempDir("TestRetentionPolicy", t)
+	defer os.RemoveAll(dir)
+
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	currentTime = func() time.Time { return now }
+	defer func() { currentTime = time.Now }()
+
+	lf := logFile(dir)
+	prefix, suffix := splitFilename(lf)
+	for i := 1; i <= 5; i++ {
+		ts := now.Add(-time.Duration(i) * 12 * time.Hour)
+		name := filepath.Join(dir, prefix+ts.Format(backupTimeFormat)+suffix)
+		require.NoError(t, ioutil.WriteFile(name, []byte("123456789"), fileMode))
+	}
+
+	var got []Backup
+	policy := RetentionPolicyFunc(func(aNow time.Time, aBackups []Backup) []Backup {
+		got = aBackups
+		return GFSPolicy(RetentionTier{Age: 30 * time.Hour}).Expired(aNow, aBackups)
+	})
+
+	l := New(WithLogFile(lf), WithRetentionPolicy(policy), WithMaxBackups(4), UseCompression)
+	defer l.Close()
+
+	forRemove, forCompress, err := l.collectFilesForSweep()
+	require.NoError(t, err)
+
+	// Oldest removed by count limit before policy applied
+	require.Equal(t, 4, len(got))
+	assert.True(t, got[0].Time.After(got[1].Time))
+	assert.Equal(t, int64(9), got[0].Size)
+
+	assert.Equal(t, 3, len(forRemove))
+	assert.Equal(t, 2, len(forCompress))
+}
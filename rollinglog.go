@@ -1,9 +1,10 @@
 package rollinglog
 
 import (
+	"bufio"
+	"compress/gzip"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"os"
 	"path/filepath"
 	"sort"
@@ -22,6 +23,9 @@ const (
 	fileMode                = 0644
 )
 
+// currentTime returns current time. Replaced in tests.
+var currentTime = time.Now
+
 // ErrHandler function called on error in logging
 type ErrHandler func(error)
 
@@ -36,19 +40,75 @@ var _ io.WriteCloser = (*Logger)(nil)
 type Logger struct {
 	filename          string
 	sizeLimit         uint64
-	backupsDaysLimit  int
+	linesLimit        uint64
+	rotateEvery       time.Duration
+	rotateAt          time.Duration
+	maxAge            time.Duration
 	backupsCountLimit int
+	totalBytesLimit   uint64
+	retention         RetentionPolicy
+	sweepInterval     time.Duration
+	lowWatermark      uint64
+	highWatermark     uint64
+	degradedMode      DegradedMode
+	policy            RotationPolicy
+	oversize          OversizeMode
+	lineBuffering     bool
 	compress          bool
+	compressor        Compressor
+	archiver          Archiver
+	hooks             Hooks
+	namer             BackupNamer
+	pattern           string
+	symlink           string
+	backupDir         string
+	partitions        string
 	localtime         bool
+	checkFile         bool
+	recovery          bool
+	processLock       bool
+	checkInterval     time.Duration
+	asyncBuffer       int
+	writeBuffer       int
+	syncPolicy        SyncPolicy
+	overflow          OverflowPolicy
 	errHandler        ErrHandler
 
-	size     uint64
+	state    FileState
+	pending  []byte
 	file     *os.File
+	buf      *bufio.Writer
+	unsynced uint64
 	lock     sync.Mutex
 	wg       sync.WaitGroup
 	shutdown int32
 
-	sweepings int32
+	nextRotation time.Time
+	rotateTimer  *time.Timer
+	sweepTimer   *time.Timer
+	expiring     bool
+	janitorDone  chan struct{}
+	lastCheck    time.Time
+	syncTimer    *time.Timer
+
+	degraded       bool
+	lastSpaceCheck time.Time
+	pruning        int32
+
+	reopenSignal os.Signal
+	signals      chan os.Signal
+	signalsDone  chan struct{}
+
+	queue *asyncQueue
+
+	sweepings    int32
+	sweepAgain   int32
+	sweepPending bool
+	recovered    int32
+	sweepLock    sync.Mutex
+	backupLock   sync.Mutex
+	events       []interface{}
+	nameLock     sync.RWMutex
 }
 
 // New create logger for log writed to aFilename
@@ -64,44 +124,72 @@ func New(options ...Option) *Logger {
 		o(l)
 	}
 
+	if l.pattern != "" {
+		l.filename = renderPattern(l.pattern, l.now())
+	}
+
+	if l.asyncBuffer > 0 {
+		l.queue = newAsyncQueue(l.asyncBuffer, l.overflow, l.writeQueued)
+	}
+
+	// Signal received before first write must not kill the process
+	l.startReopenHandler()
+
 	return l
 }
 
 func (l *Logger) runSweeping() {
 	// No need any post rotate actions
-	if l.backupsDaysLimit == 0 && l.backupsCountLimit == 0 && !l.compress {
+	if l.maxAge == 0 && l.backupsCountLimit == 0 && l.totalBytesLimit == 0 &&
+		l.retention == nil && !l.compress && atomic.LoadInt32(&l.pruning) == 0 {
+		return
+	}
+
+	// Close waits for running sweeper
+	if l.needShutdown() {
 		return
 	}
 
-	if atomic.LoadInt32(&l.sweepings) == 0 {
+	// Running sweeper could check backups before rotation already
+	atomic.StoreInt32(&l.sweepAgain, 1)
+	if atomic.CompareAndSwapInt32(&l.sweepings, 0, 1) {
 		l.wg.Add(1)
 		go l.sweep()
 	}
 }
 
-func (l *Logger) collectFilesForSweep() (forRemove, forCompress []string, err error) {
+// removal is backup selected for removal
+type removal struct {
+	path   string
+	size   int64
+	reason RemoveReason
+}
+
+func (l *Logger) collectFilesForSweep() (forRemove []removal, forCompress []string, err error) {
 	// Get all backups for current log file
-	backups, err := filterBackups(l.filename)
+	backups, err := l.backups()
 
 	if err != nil {
 		return nil, nil, err
 	}
 
-	dir := filepath.Dir(l.filename)
+	dir := l.backupsRoot()
+	take := func(b backupInfo, aReason RemoveReason) {
+		forRemove = append(forRemove, removal{filepath.Join(dir, b.name), b.size, aReason})
+	}
 
 	// Doesn't matter compressed backups on not, because
 	// compression process remove non compressed file
 
 	// Take old files first
-	if l.backupsDaysLimit > 0 {
-		diff := time.Duration(int64(24*time.Hour) * int64(l.backupsDaysLimit))
-		cutoff := time.Now().Add(-1 * diff)
+	if l.maxAge > 0 {
+		cutoff := currentTime().Add(-1 * l.maxAge)
 
 		// backups ordered by timestamp
 		for len(backups) > 0 {
 			b := backups[len(backups)-1]
 			if b.timestamp.Before(cutoff) {
-				forRemove = append(forRemove, filepath.Join(dir, b.name))
+				take(b, RemoveAge)
 				backups = backups[:len(backups)-1]
 			} else {
 				break
@@ -112,7 +200,29 @@ func (l *Logger) collectFilesForSweep() (forRemove, forCompress []string, err er
 	// Take files under limit
 	if l.backupsCountLimit > 0 {
 		for l.backupsCountLimit < len(backups) {
-			forRemove = append(forRemove, filepath.Join(dir, backups[len(backups)-1].name))
+			take(backups[len(backups)-1], RemoveCount)
+			backups = backups[:len(backups)-1]
+		}
+	}
+
+	// Take files expired by retention policy
+	backups, expired := l.applyRetention(backups)
+	for _, b := range expired {
+		take(b, RemoveRetention)
+	}
+
+	// Take oldest files over total size limit. Backups pending compression
+	// counted uncompressed, so failing compression doesn't disable the limit
+	if l.totalBytesLimit > 0 {
+		total := l.activeSize()
+		for _, b := range backups {
+			total += uint64(b.size)
+		}
+
+		for len(backups) > 0 && sizeExceeded(total, l.totalBytesLimit) {
+			b := backups[len(backups)-1]
+			take(b, RemoveTotalSize)
+			total -= uint64(b.size)
 			backups = backups[:len(backups)-1]
 		}
 	}
@@ -120,7 +230,7 @@ func (l *Logger) collectFilesForSweep() (forRemove, forCompress []string, err er
 	// Check rest for compress
 	if l.compress {
 		for _, b := range backups {
-			if !strings.HasSuffix(b.name, compressSuffix) {
+			if !b.compressed {
 				forCompress = append(forCompress, filepath.Join(dir, b.name))
 			}
 		}
@@ -129,118 +239,387 @@ func (l *Logger) collectFilesForSweep() (forRemove, forCompress []string, err er
 	return
 }
 
+// activeSize returns size of active log file on disk
+func (l *Logger) activeSize() uint64 {
+	info, err := os.Stat(l.logFilename())
+	if err != nil {
+		return 0
+	}
+	return uint64(info.Size())
+}
+
+// compression returns compressor for backups
+func (l *Logger) compression() Compressor {
+	if l.compressor == nil {
+		return GzipCompressor(gzip.DefaultCompression)
+	}
+	return l.compressor
+}
+
 func (l *Logger) needShutdown() bool {
 	return atomic.LoadInt32(&l.shutdown) == 1
 }
 
 func (l *Logger) sweep() {
-	atomic.StoreInt32(&l.sweepings, 1)
-	defer func() {
-		atomic.StoreInt32(&l.sweepings, 0)
-		l.wg.Done()
-	}()
+	defer l.wg.Done()
 
-	// Trying while has to do something
 	for {
-		if l.needShutdown() {
-			break
+		atomic.StoreInt32(&l.sweepAgain, 0)
+		l.sweepBackups()
+		atomic.StoreInt32(&l.sweepings, 0)
+
+		// Requested while sweeping, run again unless other sweeper started
+		if atomic.LoadInt32(&l.sweepAgain) == 0 || !atomic.CompareAndSwapInt32(&l.sweepings, 0, 1) {
+			return
 		}
-		forRemove, forCompress, err := l.collectFilesForSweep()
+	}
+}
 
-		if len(forRemove) == 0 && len(forCompress) == 0 {
-			// Nothong todo
-			if err != nil {
-				l.errHandler(err)
-			}
+// sweepBackups removes and compresses backups while there is something to do
+func (l *Logger) sweepBackups() {
+	// Trying while has to do something
+	for !l.needShutdown() {
+		done := true
+		err := l.withSweepLock(func() error {
+			// Fix leftovers of previous run on first sweeping
+			l.recoverOnce()
+
+			l.pruneBackups()
+			done = l.sweepStep()
+			return nil
+		})
+		l.fireEvents()
+
+		if err != nil {
+			l.errHandler(err)
 			return
 		}
+		if done {
+			return
+		}
+	}
+}
+
+// sweepStep removes and compresses backups. Returns true when nothing to do
+// or sweeping should be stopped.
+func (l *Logger) sweepStep() bool {
+	// Backups are not shifted while removed
+	l.backupLock.Lock()
+	forRemove, forCompress, err := l.collectFilesForSweep()
+
+	if len(forRemove) == 0 && len(forCompress) == 0 {
+		if err == nil {
+			l.scheduleExpiry()
+		}
+		l.backupLock.Unlock()
+		// Nothong todo
+		if err != nil {
+			l.errHandler(err)
+		}
+		return true
+	}
 
+	// Archived backups removed one by one without holding lock
+	if l.archiver == nil {
 		for _, r := range forRemove {
-			if err := os.Remove(r); err != nil {
+			if err := os.Remove(r.path); err != nil {
 				l.errHandler(err)
+				continue
 			}
+			removeEmptyDirs(filepath.Dir(r.path), l.backupsRoot())
+			l.queueEvent(RemoveEvent{Filename: r.path, Size: r.size, Reason: r.reason})
 		}
+		forRemove = nil
+	}
+	l.backupLock.Unlock()
 
-		for _, f := range forCompress {
-			if l.needShutdown() {
-				break
-			}
+	for _, r := range forRemove {
+		if l.needShutdown() {
+			return true
+		}
 
-			if err := newCompressor(f).Compress(); err != nil {
-				l.errHandler(err)
-				// Stop when has errors. We'll try another time
-				return
-			}
+		if err := l.archiveBackup(r); err != nil {
+			l.errHandler(err)
+			// Keep backup and stop. We'll try another time
+			return true
+		}
+	}
+
+	for _, f := range forCompress {
+		if l.needShutdown() {
+			break
+		}
+
+		if err := l.compressBackup(f); err != nil {
+			l.errHandler(err)
+			// Stop when has errors. We'll try another time
+			return true
 		}
 	}
+
+	return false
 }
 
-func (l *Logger) rotate() error {
-	dir, fname := filepath.Split(l.filename)
+// compressBackup compresses backup aPath unless it was shifted already
+func (l *Logger) compressBackup(aPath string) error {
+	l.backupLock.Lock()
+	defer l.backupLock.Unlock()
+
+	info, err := os.Stat(aPath)
+	if os.IsNotExist(err) || l.isActive(aPath) {
+		return nil
+	}
 
-	prefix, suffix := splitFilename(fname)
+	start := time.Now()
+	c := newCompressor(aPath, l.compression())
+	if err = c.Compress(); err != nil {
+		return err
+	}
 
-	t := time.Now()
-	if !l.localtime {
-		t = t.UTC()
+	event := CompressEvent{Source: aPath, Destination: c.destFile, Duration: time.Since(start)}
+	if info != nil {
+		event.Size = info.Size()
+	}
+	if info, err = os.Stat(c.destFile); err == nil {
+		event.CompressedSize = info.Size()
 	}
+	l.queueEvent(event)
+
+	return nil
+}
 
-	ts := t.Format(backupTimeFormat)
-	backupFile := filepath.Join(dir, fmt.Sprintf("%s%s%s", prefix, ts, suffix))
-	if err := os.Rename(l.filename, backupFile); err != nil {
+// rotate renames log file in aState to backup because of aReason
+func (l *Logger) rotate(aReason RotateReason, aState FileState) error {
+	start := time.Now()
+	backup, err := l.renameToBackup()
+	if err != nil {
 		return err
 	}
 
-	l.runSweeping()
+	l.hooks.rotate(RotateEvent{
+		Filename: l.filename,
+		Backup:   backup,
+		Reason:   aReason,
+		State:    aState,
+		Duration: time.Since(start),
+	})
+
+	// Swept when new log file opened, so size of active log file is known
+	l.sweepPending = true
 	return nil
 }
 
-func (l *Logger) create() (*os.File, uint64, error) {
+// renameToBackup shifts existing backups if required by namer and renames
+// log file to new backup. Returns path of backup.
+func (l *Logger) renameToBackup() (string, error) {
+	l.backupLock.Lock()
+	defer l.backupLock.Unlock()
+
+	now := l.now()
+	dir := l.backupDirFor(now)
+	fname := filepath.Base(l.filename)
+	namer := l.backupNamer()
+	cSuffixes := compressedSuffixes(l.compression().Suffix())
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", errors.Wrapf(err, "can't make backups directory %s", dir)
+	}
+
+	if s, ok := namer.(BackupShifter); ok {
+		if err := s.Shift(dir, fname, cSuffixes); err != nil {
+			return "", err
+		}
+	}
+
+	backup := uniqueBackupName(dir, fname, now, namer, cSuffixes)
+	return backup, os.Rename(l.filename, backup)
+}
+
+// uniqueBackupName returns name of backup for time aTime which doesn't clash
+// with existing backups in any form. Sequence number increased when several
+// rotations happen at the same time.
+func uniqueBackupName(aDir, aLogName string, aTime time.Time, aNamer BackupNamer, aCompressedSuffixes []string) string {
+	for seq := 0; ; seq++ {
+		name := filepath.Join(aDir, aNamer.Name(aLogName, aTime, seq))
+		if !backupExists(name, aCompressedSuffixes) {
+			return name
+		}
+	}
+}
+
+// backupExists checks backup aName exists uncompressed, compressed or
+// while compression
+func backupExists(aName string, aCompressedSuffixes []string) bool {
+	if _, err := os.Lstat(aName); !os.IsNotExist(err) {
+		return true
+	}
+
+	for _, cs := range aCompressedSuffixes {
+		if _, err := os.Lstat(aName + cs); !os.IsNotExist(err) {
+			return true
+		}
+		if _, err := os.Lstat(aName + cs + tempSuffix); !os.IsNotExist(err) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// seqSuffix returns part of backup name with sequence number
+func seqSuffix(aSeq int) string {
+	if aSeq == 0 {
+		return ""
+	}
+	return fmt.Sprintf("-%d", aSeq)
+}
+
+// rotateFile closes current file, renames it to backup and creates new one.
+func (l *Logger) rotateFile(aReason RotateReason) (err error) {
+	state := l.state
+	if err = l.close(); err != nil {
+		return errors.Wrapf(err, "can't close for rotate on write %s", l.filename)
+	}
+	if err = l.rotate(aReason, state); err != nil {
+		return errors.Wrapf(err, "can't rotate on write %s", l.filename)
+	}
+	if l.file, l.state, err = l.create(); err != nil {
+		return errors.Wrapf(err, "write failed")
+	}
+
+	l.fileOpened()
+	return nil
+}
+
+func (l *Logger) create() (*os.File, FileState, error) {
 	dir := filepath.Dir(l.filename)
 	if err := os.MkdirAll(dir, 0755); err != nil {
-		return nil, 0, errors.Wrapf(err, "can't make directories for %s", dir)
+		return nil, FileState{}, errors.Wrapf(err, "can't make directories for %s", dir)
 	}
 
-	f, err := os.OpenFile(l.filename, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, fileMode)
+	f, err := os.OpenFile(l.filename, os.O_CREATE|os.O_WRONLY|os.O_TRUNC|os.O_APPEND, fileMode)
 	if err != nil {
-		return nil, 0, errors.Wrapf(err, "can't create file %s", l.filename)
+		return nil, FileState{}, errors.Wrapf(err, "can't create file %s", l.filename)
 	}
 
-	return f, 0, nil
+	l.hooks.open(OpenEvent{Filename: l.filename, Created: true})
+	return f, FileState{}, nil
 }
 
-func (l *Logger) openOrCreate(aNeedWrite uint64) (*os.File, uint64, error) {
+func (l *Logger) openOrCreate(aPending []byte) (*os.File, FileState, error) {
 	info, err := os.Stat(l.filename)
 	if os.IsNotExist(err) {
 		return l.create()
 	}
 	if err != nil {
-		return nil, 0, errors.Wrapf(err, "can't stat %s", l.filename)
+		return nil, FileState{}, errors.Wrapf(err, "can't stat %s", l.filename)
+	}
+
+	state := FileState{Size: uint64(info.Size())}
+
+	// Lines are required by limit or policy only
+	if l.linesLimit > 0 || l.policy != nil {
+		if state.Lines, err = countLines(l.filename); err != nil {
+			return nil, FileState{}, err
+		}
 	}
 
-	curSize := uint64(info.Size())
+	reason, due := RotateTime, l.fromPastPeriod(info)
+	if !due {
+		reason, due = l.rotationReason(state, aPending)
+	}
 
-	if sizeExceeded(aNeedWrite+curSize, l.sizeLimit) {
-		if err = l.rotate(); err != nil {
-			return nil, 0, errors.Wrapf(err, "can't rotate %s", l.filename)
+	if state.Size > 0 && due {
+		if err = l.rotate(reason, state); err != nil {
+			return nil, FileState{}, errors.Wrapf(err, "can't rotate %s", l.filename)
 		}
 		return l.create()
 	}
 
 	file, err := os.OpenFile(l.filename, os.O_APPEND|os.O_WRONLY, fileMode)
 	if err != nil {
-		return nil, 0, errors.Wrapf(err, "can't open %s", l.filename)
+		return nil, FileState{}, errors.Wrapf(err, "can't open %s", l.filename)
 	}
 
-	return file, curSize, nil
+	l.hooks.open(OpenEvent{Filename: l.filename, Size: state.Size})
+	return file, state, nil
+}
+
+func (l *Logger) fileCheckDue() bool {
+	if !l.checkFile {
+		return false
+	}
+
+	return l.checkInterval <= 0 || currentTime().Sub(l.lastCheck) >= l.checkInterval
+}
+
+// reopenIfMoved reopens log file if opened one was moved or deleted
+func (l *Logger) reopenIfMoved() error {
+	l.lastCheck = currentTime()
+
+	opened, err := l.file.Stat()
+	if err != nil {
+		return errors.Wrapf(err, "can't stat opened %s", l.filename)
+	}
+
+	info, err := os.Stat(l.filename)
+	if err == nil && os.SameFile(opened, info) {
+		return nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "can't stat %s", l.filename)
+	}
+
+	l.errHandler(errors.Errorf("log file %s was moved or deleted, reopening", l.filename))
+	return l.reopen()
+}
+
+// openFile opens existing log file or creates new one
+func (l *Logger) openFile(aPending []byte) (err error) {
+	if err = l.checkPattern(); err != nil {
+		return err
+	}
+
+	l.startupRecovery()
+
+	if l.file, l.state, err = l.openOrCreate(aPending); err != nil {
+		return err
+	}
+
+	l.fileOpened()
+	return nil
+}
+
+// fileOpened called each time new log file opened
+func (l *Logger) fileOpened() {
+	if l.writeBuffer > 0 {
+		l.buf = bufio.NewWriterSize(l.file, l.writeBuffer)
+	}
+	l.lastCheck = currentTime()
+	l.state.Opened = currentTime()
+	l.scheduleRotation()
+	if l.sweepPending {
+		l.sweepPending = false
+		l.runSweeping()
+	}
+	l.startExpiry()
+	l.startJanitor()
+	l.startReopenHandler()
+
+	if err := l.updateSymlink(); err != nil {
+		l.errHandler(err)
+	}
 }
 
 func (l *Logger) close() (err error) {
+	errs := new(multierror.Error)
+	errs = multierror.Append(errs, l.flushFile())
+
 	f := l.file
-	l.size = 0
+	l.state = FileState{}
 	l.file = nil
-
-	errs := new(multierror.Error)
+	l.buf = nil
+	l.unsynced = 0
 
 	if f != nil {
 		errs = multierror.Append(errs, f.Sync())
@@ -252,64 +631,210 @@ func (l *Logger) close() (err error) {
 
 // Write implements io.Writer interface
 func (l *Logger) Write(p []byte) (n int, err error) {
+	if l.queue != nil {
+		l.queue.push(p)
+		return len(p), nil
+	}
+
 	l.lock.Lock()
 	defer l.lock.Unlock()
 
-	writeLen := uint64(len(p))
+	return l.writeData(p)
+}
 
-	if sizeExceeded(writeLen, l.sizeLimit) {
-		return 0, errors.Errorf("write length %d exceeds file size limit %d", writeLen, l.sizeLimit)
+// writeData writes data holding rotation lock between processes
+func (l *Logger) writeData(p []byte) (n int, err error) {
+	if done, n, err := l.writeDegraded(p); done {
+		return n, err
 	}
 
-	if l.file == nil {
-		if l.file, l.size, err = l.openOrCreate(writeLen); err != nil {
+	err = l.withRotationLock(func() (e error) {
+		n, e = l.writeRecords(p)
+		return e
+	})
+
+	return n, err
+}
+
+// writeRecords writes data according to buffering and oversize modes
+func (l *Logger) writeRecords(p []byte) (n int, err error) {
+	if err = l.followPattern(); err != nil {
+		return 0, errors.Wrap(err, "write failed")
+	}
+
+	if l.lineBuffering {
+		return l.writeBuffered(p)
+	}
+
+	if !l.oversized(p) {
+		return l.write(p)
+	}
+
+	switch l.oversize {
+	case SplitOversized:
+		return l.writeSplit(p)
+	case AllowOversized:
+		return l.write(p)
+	}
+
+	return 0, l.oversizeError(p)
+}
+
+// write writes aData into log file with rotation if required
+func (l *Logger) write(p []byte) (n int, err error) {
+	if l.file != nil && l.fileCheckDue() {
+		if err = l.reopenIfMoved(); err != nil {
 			return 0, errors.Wrap(err, "write failed")
 		}
 	}
 
-	if sizeExceeded(l.size+writeLen, l.sizeLimit) {
-		if err = l.close(); err != nil {
-			return 0, errors.Wrapf(err, "can't close for rotate on write %s", l.filename)
+	if l.file == nil {
+		if err = l.openFile(p); err != nil {
+			return 0, errors.Wrap(err, "write failed")
 		}
-		if err = l.rotate(); err != nil {
-			return 0, errors.Wrapf(err, "can't rotate on write %s", l.filename)
+	}
+
+	if l.rotationDue() && l.state.Size == 0 {
+		// Nothing was written during the period, so just wait next one
+		l.scheduleRotation()
+	}
+
+	// Empty file never rotated, so oversized write goes into fresh file
+	if l.state.Size > 0 {
+		reason, due := RotateTime, l.rotationDue()
+		if !due {
+			reason, due = l.rotationReason(l.state, p)
 		}
-		if l.file, l.size, err = l.create(); err != nil {
-			return 0, errors.Wrapf(err, "write failed")
+
+		if due {
+			if err = l.rotateFile(reason); err != nil {
+				return 0, err
+			}
 		}
 	}
 
-	n, err = l.file.Write(p)
-	l.size += uint64(n)
+	n, err = l.writeFile(p)
+	l.state.written(p[:n])
 
 	return n, err
 }
 
+// Rotate forces rotation: current log file closed and renamed to backup,
+// then new log file created.
+func (l *Logger) Rotate() error {
+	// Data written before goes into current file
+	if l.queue != nil {
+		l.queue.flush()
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return l.withRotationLock(l.forceRotate)
+}
+
+func (l *Logger) forceRotate() (err error) {
+	state := l.state
+	if err = l.close(); err != nil {
+		return errors.Wrapf(err, "can't close for rotate %s", l.filename)
+	}
+
+	if info, err := os.Stat(l.filename); err == nil {
+		// Log file could be not opened yet
+		state.Size = uint64(info.Size())
+		if err = l.rotate(RotateForced, state); err != nil {
+			return errors.Wrapf(err, "can't rotate %s", l.filename)
+		}
+	} else if !os.IsNotExist(err) {
+		return errors.Wrapf(err, "can't stat %s", l.filename)
+	}
+
+	if l.file, l.state, err = l.create(); err != nil {
+		return errors.Wrap(err, "rotate failed")
+	}
+
+	l.fileOpened()
+	return nil
+}
+
+// Reopen closes current log file and opens it again. Useful when log file
+// was moved by external tool (e.g. logrotate).
+func (l *Logger) Reopen() error {
+	// Data written before goes into current file
+	if l.queue != nil {
+		l.queue.flush()
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return l.reopen()
+}
+
+func (l *Logger) reopen() (err error) {
+	if err = l.close(); err != nil {
+		return errors.Wrapf(err, "can't close for reopen %s", l.filename)
+	}
+
+	if err = l.openFile(nil); err != nil {
+		return errors.Wrap(err, "reopen failed")
+	}
+
+	return nil
+}
+
 // Close implements io.Closer interface
 func (l *Logger) Close() error {
+	// Writer goroutine needs lock to write queued data
+	if l.queue != nil {
+		l.queue.close()
+	}
+
 	l.lock.Lock()
 	defer l.lock.Unlock()
 
-	if atomic.LoadInt32(&l.sweepings) == 1 {
-		atomic.StoreInt32(&l.shutdown, 1)
-		l.wg.Wait()
-		atomic.StoreInt32(&l.shutdown, 0)
+	l.stopRotationTimer()
+	l.stopReopenHandler()
+	l.stopSyncTimer()
+	l.stopJanitor()
+
+	errs := new(multierror.Error)
+	if len(l.pending) > 0 {
+		errs = multierror.Append(errs, l.withRotationLock(l.flushPending))
 	}
 
-	return l.close()
+	// Sweeper may be started but not running yet, so wait anyway. Hooks
+	// called by sweeper may write, so wait without lock.
+	atomic.StoreInt32(&l.shutdown, 1)
+	l.lock.Unlock()
+	l.wg.Wait()
+	l.lock.Lock()
+	atomic.StoreInt32(&l.shutdown, 0)
+	l.stopSweepTimer()
+
+	errs = multierror.Append(errs, l.removeSymlink())
+	errs = multierror.Append(errs, l.close())
+	return errs.ErrorOrNil()
 }
 
 // backupInfo is a convenience struct to return the filename and its embedded
 // timestamp.
 type backupInfo struct {
-	name      string
-	timestamp time.Time
+	name       string
+	timestamp  time.Time
+	seq        int
+	size       int64
+	compressed bool
 }
 
-// byTimestamp sorts by newest time formatted in the name.
+// byTimestamp sorts by newest time formatted in the name and by greater
+// sequence number for the same time.
 type byTimestamp []backupInfo
 
 func (b byTimestamp) Less(i, j int) bool {
+	if b[i].timestamp.Equal(b[j].timestamp) {
+		return b[i].seq > b[j].seq
+	}
 	return b[i].timestamp.After(b[j].timestamp)
 }
 
@@ -330,27 +855,45 @@ func splitFilename(aFileName string) (prefix string, suffix string) {
 	return
 }
 
-// Filter list of files from dir of aBaseFile
-// Result sorted by timestamp.
-func filterBackups(aLogFilename string) ([]backupInfo, error) {
-	files, err := ioutil.ReadDir(filepath.Dir(aLogFilename))
+// Filter list of files from aDir (or its subdirectories of aDepth level)
+// named by aNamer as backups of aLogName. Backups with any of registered
+// compressor suffixes or aCompressedSuffixes recognised as compressed.
+// Names of backups are relative to aDir. Result sorted by timestamp.
+func filterBackups(aDir, aLogName string, aDepth int, aNamer BackupNamer, aCompressedSuffixes ...string) ([]backupInfo, error) {
+	files, err := listFiles(aDir, aDepth)
 	if err != nil {
-		return nil, errors.Wrap(err, "can't read log file directory: %s")
+		return nil, err
 	}
 
 	result := []backupInfo{}
 
-	prefix, suffix := splitFilename(aLogFilename)
-	cSiffix := suffix + compressSuffix
+	cSuffixes := compressedSuffixes(aCompressedSuffixes...)
+
+	parse := func(aFile backupFile, aSuffix string) bool {
+		if !strings.HasSuffix(aFile.info.Name(), aSuffix) {
+			return false
+		}
+
+		ts, seq, err := aNamer.Parse(aLogName, strings.TrimSuffix(aFile.info.Name(), aSuffix))
+		if err != nil {
+			return false
+		}
+		if ts.IsZero() {
+			ts = aFile.info.ModTime()
+		}
+
+		result = append(result, backupInfo{aFile.path, ts, seq, aFile.info.Size(), aSuffix != ""})
+		return true
+	}
 
 	for _, f := range files {
-		if f.IsDir() {
+		if parse(f, "") {
 			continue
 		}
-		if ts, err := timeFormFilename(f.Name(), prefix, suffix); err == nil {
-			result = append(result, backupInfo{f.Name(), ts})
-		} else if ts, err := timeFormFilename(f.Name(), prefix, cSiffix); err == nil {
-			result = append(result, backupInfo{f.Name(), ts})
+		for _, cs := range cSuffixes {
+			if parse(f, cs) {
+				break
+			}
 		}
 	}
 
@@ -366,18 +909,64 @@ func sizeExceeded(aSize, aLimitSize uint64) bool {
 	return aSize > aLimitSize
 }
 
-func timeFormFilename(aFilename, aPrefix, aSuffix string) (time.Time, error) {
+// backupID returns timestamp formatted with aLayout in aLocation and sequence
+// number embedded into backup name
+func backupID(aFilename, aPrefix, aSuffix, aLayout string, aLocation *time.Location) (time.Time, int, error) {
+	id, err := backupIDPart(aFilename, aPrefix, aSuffix)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+
+	if ts, err := time.ParseInLocation(aLayout, id, aLocation); err == nil {
+		return ts, 0, nil
+	}
+
+	i := strings.LastIndexByte(id, '-')
+	if i < 0 {
+		return time.Time{}, 0, errors.New("no time field")
+	}
+
+	seq, err := parseSeq(id[i:])
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+
+	ts, err := time.ParseInLocation(aLayout, id[:i], aLocation)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+
+	return ts, seq, nil
+}
+
+// backupIDPart returns part of backup name between aPrefix and aSuffix
+func backupIDPart(aFilename, aPrefix, aSuffix string) (string, error) {
 	if !strings.HasPrefix(aFilename, aPrefix) {
-		return time.Time{}, errors.New("mismatch prefix")
+		return "", errors.New("mismatch prefix")
 	}
 	if !strings.HasSuffix(aFilename, aSuffix) {
-		return time.Time{}, errors.New("mismatch prefix")
+		return "", errors.New("mismatch prefix")
+	}
+	if len(aFilename) <= len(aPrefix)+len(aSuffix) {
+		return "", errors.New("no time field")
 	}
 
-	if len(backupTimeFormat) == len(aFilename)-len(aSuffix)-len(aPrefix) {
-		ts := aFilename[len(aPrefix) : len(aFilename)-len(aSuffix)]
-		return time.Parse(backupTimeFormat, ts)
+	return aFilename[len(aPrefix) : len(aFilename)-len(aSuffix)], nil
+}
+
+// parseSeq parses sequence number part of backup name
+func parseSeq(aSeq string) (int, error) {
+	if len(aSeq) < 2 || aSeq[0] != '-' || aSeq[1] == '0' {
+		return 0, errors.New("invalid sequence field")
+	}
+
+	seq := 0
+	for _, c := range aSeq[1:] {
+		if c < '0' || c > '9' {
+			return 0, errors.New("invalid sequence field")
+		}
+		seq = seq*10 + int(c-'0')
 	}
 
-	return time.Time{}, errors.New("no time field")
+	return seq, nil
 }
@@ -1,6 +1,7 @@
 package rollinglog
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -20,7 +21,7 @@ func TestDefaults(t *testing.T) {
 	l := New()
 
 	assert.Equal(t, uint64(0), l.sizeLimit)
-	assert.Equal(t, 0, l.backupsDaysLimit)
+	assert.Equal(t, time.Duration(0), l.maxAge)
 	assert.Equal(t, 0, l.backupsCountLimit)
 	assert.False(t, l.compress)
 	assert.False(t, l.localtime)
@@ -117,6 +118,146 @@ func TestFirstWriteWithRotate(t *testing.T) {
 	require.NoError(t, l.Close())
 }
 
+func TestRotate(t *testing.T) {
+	dir := makeTempDir("TestRotate", t)
+	defer os.RemoveAll(dir)
+
+	lf := logFile(dir)
+	l := New(WithLogFile(lf), WithMaxBackups(1))
+	defer l.Close()
+
+	// Nothing to rotate yet, just creates log file
+	require.NoError(t, l.Rotate())
+	existsWithContent(lf, []byte{}, t)
+
+	count, err := getFilesInDir(t, dir)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	b := []byte("123456789")
+	n, err := l.Write(b)
+	require.NoError(t, err)
+	assert.Equal(t, len(b), n)
+
+	require.NoError(t, l.Rotate())
+	existsWithContent(lf, []byte{}, t)
+
+	count, err = getFilesInDir(t, dir)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	<-time.After(time.Millisecond * 10)
+
+	n, err = l.Write(b)
+	require.NoError(t, err)
+	assert.Equal(t, len(b), n)
+	existsWithContent(lf, b, t)
+
+	require.NoError(t, l.Rotate())
+	l.wg.Wait()
+
+	count, err = getFilesInDir(t, dir)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
+
+func TestReopen(t *testing.T) {
+	dir := makeTempDir("TestReopen", t)
+	defer os.RemoveAll(dir)
+
+	lf := logFile(dir)
+	l := New(WithLogFile(lf))
+	defer l.Close()
+
+	b := []byte("123456789")
+	_, err := l.Write(b)
+	require.NoError(t, err)
+
+	moved := lf + ".1"
+	require.NoError(t, os.Rename(lf, moved))
+
+	_, err = l.Write(b)
+	require.NoError(t, err)
+	existsWithContent(moved, append(b, b...), t)
+
+	require.NoError(t, l.Reopen())
+
+	_, err = l.Write(b)
+	require.NoError(t, err)
+	existsWithContent(lf, b, t)
+	existsWithContent(moved, append(b, b...), t)
+}
+
+func TestFileCheck(t *testing.T) {
+	dir := makeTempDir("TestFileCheck", t)
+	defer os.RemoveAll(dir)
+
+	var errs []error
+
+	lf := logFile(dir)
+	l := New(WithLogFile(lf), WithFileCheck(0), WithErrorHandler(func(err error) {
+		errs = append(errs, err)
+	}))
+	defer l.Close()
+
+	b := []byte("123456789")
+	_, err := l.Write(b)
+	require.NoError(t, err)
+
+	_, err = l.Write(b)
+	require.NoError(t, err)
+	existsWithContent(lf, append(b, b...), t)
+	assert.Empty(t, errs)
+
+	// Moved
+	moved := lf + ".1"
+	require.NoError(t, os.Rename(lf, moved))
+
+	_, err = l.Write(b)
+	require.NoError(t, err)
+	existsWithContent(lf, b, t)
+	existsWithContent(moved, append(b, b...), t)
+	assert.Len(t, errs, 1)
+
+	// Deleted
+	require.NoError(t, os.Remove(lf))
+
+	_, err = l.Write(b)
+	require.NoError(t, err)
+	existsWithContent(lf, b, t)
+	assert.Len(t, errs, 2)
+}
+
+func TestFileCheckInterval(t *testing.T) {
+	dir := makeTempDir("TestFileCheckInterval", t)
+	defer os.RemoveAll(dir)
+
+	now := time.Now()
+	currentTime = func() time.Time { return now }
+	defer func() { currentTime = time.Now }()
+
+	lf := logFile(dir)
+	l := New(WithLogFile(lf), WithFileCheck(time.Minute))
+	defer l.Close()
+
+	b := []byte("123456789")
+	_, err := l.Write(b)
+	require.NoError(t, err)
+
+	moved := lf + ".1"
+	require.NoError(t, os.Rename(lf, moved))
+
+	_, err = l.Write(b)
+	require.NoError(t, err)
+	existsWithContent(moved, append(b, b...), t)
+
+	now = now.Add(time.Minute)
+
+	_, err = l.Write(b)
+	require.NoError(t, err)
+	existsWithContent(lf, b, t)
+}
+
 func TestSplitFilename(t *testing.T) {
 	tests := []struct {
 		filename   string
@@ -151,15 +292,74 @@ func TestTimeFormFilename(t *testing.T) {
 		{"kjfksjldfjks", time.Time{}, true},
 	}
 
-	prefix, ext := splitFilename("asdasdsad/foo.log")
-
 	for _, test := range tests {
-		got, err := timeFormFilename(test.filename, prefix, ext)
+		got, _, err := defaultNamer.Parse("foo.log", test.filename)
 		assert.Equal(t, test.want, got)
 		assert.Equal(t, err != nil, test.wantErr)
 	}
 }
 
+func TestBackupID(t *testing.T) {
+	tests := []struct {
+		filename string
+		want     time.Time
+		wantSeq  int
+		wantErr  bool
+	}{
+		{"foo.20140504144433.555.log", time.Date(2014, 5, 4, 14, 44, 33, 555000000, time.UTC), 0, false},
+		{"foo.20140504144433.555-1.log", time.Date(2014, 5, 4, 14, 44, 33, 555000000, time.UTC), 1, false},
+		{"foo.20140504144433.555-12.log", time.Date(2014, 5, 4, 14, 44, 33, 555000000, time.UTC), 12, false},
+		{"foo.20140504144433.555-.log", time.Time{}, 0, true},
+		{"foo.20140504144433.555-01.log", time.Time{}, 0, true},
+		{"foo.20140504144433.555-1a.log", time.Time{}, 0, true},
+		{"foo.20140504144433.555.1.log", time.Time{}, 0, true},
+		{"foo.20140504144433.555-+1.log", time.Time{}, 0, true},
+	}
+
+	prefix, ext := splitFilename("foo.log")
+
+	for _, test := range tests {
+		got, seq, err := backupID(test.filename, prefix, ext, backupTimeFormat, time.UTC)
+		assert.Equal(t, test.want, got, test.filename)
+		assert.Equal(t, test.wantSeq, seq, test.filename)
+		assert.Equal(t, test.wantErr, err != nil, test.filename)
+	}
+}
+
+func TestUniqueBackupNames(t *testing.T) {
+	dir := makeTempDir("TestUniqueBackupNames", t)
+	defer os.RemoveAll(dir)
+
+	now := time.Date(2019, 11, 4, 18, 30, 0, 0, time.UTC)
+	currentTime = func() time.Time { return now }
+	defer func() { currentTime = time.Now }()
+
+	lf := logFile(dir)
+	l := New(WithLogFile(lf), WithMaxBytes(10))
+
+	prefix, suffix := splitFilename(lf)
+	ts := now.Format(backupTimeFormat)
+
+	// Compressed backup with the same time exists
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, prefix+ts+suffix+compressSuffix), []byte("0"), 0644))
+
+	for i := 1; i <= 4; i++ {
+		_, err := l.Write([]byte(fmt.Sprintf("%d23456789", i)))
+		require.NoError(t, err)
+	}
+	require.NoError(t, l.Close())
+
+	backups, err := filterBackups(dir, filepath.Base(lf), 0, defaultNamer)
+	require.NoError(t, err)
+	require.Equal(t, 4, len(backups))
+
+	for i, b := range backups {
+		assert.Equal(t, 3-i, b.seq)
+	}
+
+	assert.Equal(t, []string{"0", "123456789", "223456789", "323456789", "423456789"}, readBackups(t, dir, lf))
+}
+
 func TestNewFile(t *testing.T) {
 	dir := makeTempDir("TestNewFile", t)
 	defer os.RemoveAll(dir)
@@ -202,6 +402,54 @@ func TestWriteTooLong(t *testing.T) {
 	assert.True(t, os.IsNotExist(err), "File exists, but should not have been created")
 }
 
+func TestRotateByLines(t *testing.T) {
+	dir := makeTempDir("TestRotateByLines", t)
+	defer os.RemoveAll(dir)
+
+	lf := logFile(dir)
+	l := New(WithLogFile(lf), WithMaxLines(3))
+
+	_, err := l.Write([]byte("1\n2\n"))
+	require.NoError(t, err)
+
+	b := []byte("3\n")
+	_, err = l.Write(b)
+	require.NoError(t, err)
+	existsWithContent(lf, []byte("1\n2\n3\n"), t)
+
+	_, err = l.Write(b)
+	require.NoError(t, err)
+	existsWithContent(lf, b, t)
+
+	count, err := getFilesInDir(t, dir)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	n, err := l.Write([]byte("1\n2\n3\n4\n"))
+	assert.EqualError(t, err, "write lines 4 exceeds file lines limit 3")
+	assert.Equal(t, 0, n)
+
+	require.NoError(t, l.Close())
+
+	// Lines count restored on open
+	<-time.After(time.Millisecond * 10)
+
+	l = New(WithLogFile(lf), WithMaxLines(3))
+	defer l.Close()
+
+	_, err = l.Write([]byte("4\n5\n"))
+	require.NoError(t, err)
+	existsWithContent(lf, []byte("3\n4\n5\n"), t)
+
+	_, err = l.Write(b)
+	require.NoError(t, err)
+	existsWithContent(lf, b, t)
+
+	count, err = getFilesInDir(t, dir)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+}
+
 func TestOpenExisting(t *testing.T) {
 	dir := makeTempDir("TestOpenExisting", t)
 	defer os.RemoveAll(dir)
@@ -275,7 +523,7 @@ func TestFilterBackups(t *testing.T) {
 		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, f), []byte(f), 0644))
 	}
 
-	lst, err := filterBackups(lf)
+	lst, err := filterBackups(dir, filepath.Base(lf), 0, defaultNamer)
 	require.NoError(t, err)
 	assert.Equal(t, 5, len(lst))
 
@@ -347,7 +595,7 @@ func TestCollectFilesForSweep(t *testing.T) {
 	assert.Equal(t, 5, len(forRemove))
 	assert.Equal(t, 0, len(forCompress))
 
-	require.NoError(t, os.Rename(forRemove[0], forRemove[0]+compressSuffix))
+	require.NoError(t, os.Rename(forRemove[0].path, forRemove[0].path+compressSuffix))
 	require.NoError(t, l.Close())
 
 	l = New(WithLogFile(lf), WithMaxBytes(10), WithMaxBackups(2), UseCompression)
@@ -404,7 +652,7 @@ func TestCollectFilesForSweep(t *testing.T) {
 	assert.Equal(t, 5, len(forCompress))
 
 	for _, r := range forRemove {
-		require.NoError(t, os.Remove(r))
+		require.NoError(t, os.Remove(r.path))
 	}
 	require.NoError(t, l.Close())
 }
@@ -463,6 +711,80 @@ func TestCompressing(t *testing.T) {
 	assert.Equal(t, 1, count)
 }
 
+func TestTotalBytesLimit(t *testing.T) {
+	dir := makeTempDir("TestTotalBytesLimit", t)
+	defer os.RemoveAll(dir)
+
+	lf := logFile(dir)
+	l := New(WithLogFile(lf), WithMaxBytes(10), WithMaxTotalBytes(30))
+	defer l.Close()
+
+	b := []byte("123456789")
+
+	for i := 0; i < 5; i++ {
+		_, err := l.Write(b)
+		require.NoError(t, err)
+	}
+
+	l.wg.Wait()
+
+	// Log file and two backups fit the limit
+	count, err := getFilesInDir(t, dir)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+}
+
+func TestTotalBytesLimitCompression(t *testing.T) {
+	dir := makeTempDir("TestTotalBytesLimitCompression", t)
+	defer os.RemoveAll(dir)
+
+	lf := logFile(dir)
+	l := New(WithLogFile(lf), WithMaxBytes(1000), WithMaxBackups(3), UseCompression)
+
+	b := bytes.Repeat([]byte("a"), 1000)
+
+	for i := 0; i < 4; i++ {
+		_, err := l.Write(b)
+		require.NoError(t, err)
+		l.wg.Wait()
+	}
+	require.NoError(t, l.Close())
+
+	count, err := gzFileCount(t, dir)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	// Budget applied to compressed backups
+	l = New(WithLogFile(lf), WithMaxTotalBytes(1010))
+	forRemove, forCompress, err := l.collectFilesForSweep()
+	require.NoError(t, err)
+	assert.Equal(t, 0, len(forCompress))
+	assert.Equal(t, 3, len(forRemove))
+}
+
+func TestTotalBytesLimitCompressionFailed(t *testing.T) {
+	dir := makeTempDir("TestTotalBytesLimitCompressionFailed", t)
+	defer os.RemoveAll(dir)
+
+	lf := logFile(dir)
+	l := New(WithLogFile(lf), WithMaxBytes(1000), WithMaxTotalBytes(900),
+		WithCompressor(failedCompressor{}), WithErrorHandler(func(error) {}))
+
+	b := bytes.Repeat([]byte("a"), 1000)
+
+	for i := 0; i < 4; i++ {
+		_, err := l.Write(b)
+		require.NoError(t, err)
+		l.wg.Wait()
+	}
+	require.NoError(t, l.Close())
+
+	// Budget applied to backups which failed to compress
+	count, err := getFilesInDir(t, dir)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
 func TestConcurency(t *testing.T) {
 	dir := makeTempDir("TestCompressing", t)
 	defer os.RemoveAll(dir)
@@ -0,0 +1,229 @@
+package rollinglog
+
+import (
+	"os"
+	"time"
+)
+
+// now returns current time in logger's timezone
+func (l *Logger) now() time.Time {
+	t := currentTime()
+	if !l.localtime {
+		t = t.UTC()
+	}
+	return t
+}
+
+// nextBoundary returns first rotation boundary after aNow
+func nextBoundary(aNow time.Time, aInterval, aOffset time.Duration) time.Time {
+	_, end := rotationPeriod(aNow, aInterval, aOffset)
+	return end
+}
+
+// rotationEpoch is a wall clock origin of periods longer than a day. It's
+// Monday, so weekly periods start on Mondays.
+var rotationEpoch = time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// rotationPeriod returns boundaries of rotation period containing aNow.
+// Periods start every aInterval from the wall clock midnight of aNow's
+// location shifted by aOffset. The last period of the day lasts until the
+// start of the next day, so intervals which don't divide a day and days of
+// daylight saving change stay aligned to midnight. Periods longer than a day
+// are counted from rotationEpoch.
+func rotationPeriod(aNow time.Time, aInterval, aOffset time.Duration) (start, end time.Time) {
+	if aInterval > 24*time.Hour {
+		return longPeriod(aNow, aInterval, aOffset)
+	}
+
+	y, m, d := aNow.Date()
+	dayStart := func(aDay int) time.Time {
+		return time.Date(y, m, aDay, 0, 0, 0, 0, aNow.Location()).Add(aOffset)
+	}
+
+	day := d + 1
+	for dayStart(day).After(aNow) {
+		day--
+	}
+	first, next := dayStart(day), dayStart(day+1)
+
+	// Count of periods in a day of nominal length
+	periods := (24*time.Hour + aInterval - 1) / aInterval
+
+	k := aNow.Sub(first) / aInterval
+	if k >= periods-1 {
+		return first.Add((periods - 1) * aInterval), next
+	}
+
+	start = first.Add(k * aInterval)
+	end = start.Add(aInterval)
+	if end.After(next) {
+		end = next
+	}
+
+	return start, end
+}
+
+// longPeriod returns boundaries of period longer than a day containing aNow.
+// Periods counted on wall clock, so they keep starting at the same time of
+// day across daylight saving changes.
+func longPeriod(aNow time.Time, aInterval, aOffset time.Duration) (start, end time.Time) {
+	wall := func(aTime time.Time) time.Time {
+		y, m, d := aTime.Date()
+		hh, mm, ss := aTime.Clock()
+		return time.Date(y, m, d, hh, mm, ss, aTime.Nanosecond(), time.UTC)
+	}
+	local := func(aWall time.Time) time.Time {
+		y, m, d := aWall.Date()
+		hh, mm, ss := aWall.Clock()
+		return time.Date(y, m, d, hh, mm, ss, aWall.Nanosecond(), aNow.Location())
+	}
+
+	origin := rotationEpoch.Add(aOffset)
+	elapsed := wall(aNow).Sub(origin)
+	k := elapsed / aInterval
+	if elapsed < 0 && elapsed%aInterval != 0 {
+		k--
+	}
+
+	start = origin.Add(k * aInterval)
+	return local(start), local(start.Add(aInterval))
+}
+
+// rotationDue checks time based rotation should be done
+func (l *Logger) rotationDue() bool {
+	if l.rotateEvery <= 0 || l.nextRotation.IsZero() {
+		return false
+	}
+
+	return !l.now().Before(l.nextRotation)
+}
+
+// fromPastPeriod checks existing log file was written in one of previous periods
+func (l *Logger) fromPastPeriod(aInfo os.FileInfo) bool {
+	if l.rotateEvery <= 0 || aInfo.Size() == 0 {
+		return false
+	}
+
+	periodStart, _ := rotationPeriod(l.now(), l.rotateEvery, l.rotateAt)
+	return aInfo.ModTime().Before(periodStart)
+}
+
+// scheduleRotation calculates next time based rotation and arms timer for it,
+// so idle logger rotated in time too.
+func (l *Logger) scheduleRotation() {
+	if l.rotateEvery <= 0 {
+		return
+	}
+
+	now := l.now()
+	l.nextRotation = nextBoundary(now, l.rotateEvery, l.rotateAt)
+	l.armRotationTimer(l.nextRotation.Sub(now))
+}
+
+func (l *Logger) armRotationTimer(aDelay time.Duration) {
+	if l.rotateTimer == nil {
+		l.rotateTimer = time.AfterFunc(aDelay, l.onRotationTimer)
+		return
+	}
+
+	l.rotateTimer.Reset(aDelay)
+}
+
+func (l *Logger) stopRotationTimer() {
+	if l.rotateTimer != nil {
+		l.rotateTimer.Stop()
+		l.rotateTimer = nil
+	}
+	l.nextRotation = time.Time{}
+}
+
+func (l *Logger) onRotationTimer() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	// Closed while waiting for lock
+	if l.file == nil {
+		return
+	}
+
+	if !l.rotationDue() {
+		l.armRotationTimer(l.nextRotation.Sub(l.now()))
+		return
+	}
+
+	err := l.withRotationLock(func() error {
+		// Other process could rotate log file already
+		if !l.rotationDue() {
+			return nil
+		}
+
+		if l.state.Size == 0 {
+			l.scheduleRotation()
+			return nil
+		}
+
+		return l.rotateFile(RotateTime)
+	})
+
+	if err != nil {
+		l.errHandler(err)
+	}
+}
+
+// startExpiry runs sweeping on first open to remove backups expired while
+// logger was not running and to schedule next expiry
+func (l *Logger) startExpiry() {
+	if l.maxAge <= 0 || l.expiring {
+		return
+	}
+
+	l.expiring = true
+	l.runSweeping()
+}
+
+// scheduleExpiry arms timer to sweep when the oldest backup expires, so
+// expired backups removed by idle logger too. Called with backupLock held.
+func (l *Logger) scheduleExpiry() {
+	if l.maxAge <= 0 || l.needShutdown() {
+		return
+	}
+
+	backups, err := l.backups()
+	if err != nil || len(backups) == 0 {
+		return
+	}
+
+	// Backup removed when it is older than cutoff, so wake up just after it
+	expiry := backups[len(backups)-1].timestamp.Add(l.maxAge)
+	delay := expiry.Sub(currentTime()) + time.Millisecond
+
+	if l.sweepTimer == nil {
+		l.sweepTimer = time.AfterFunc(delay, l.onSweepTimer)
+		return
+	}
+
+	l.sweepTimer.Reset(delay)
+}
+
+func (l *Logger) stopSweepTimer() {
+	l.backupLock.Lock()
+	defer l.backupLock.Unlock()
+
+	if l.sweepTimer != nil {
+		l.sweepTimer.Stop()
+		l.sweepTimer = nil
+	}
+	l.expiring = false
+}
+
+func (l *Logger) onSweepTimer() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	// Closed while waiting for lock
+	if l.file == nil {
+		return
+	}
+
+	l.runSweeping()
+}
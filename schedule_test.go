@@ -0,0 +1,184 @@
+package rollinglog
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNextBoundary(t *testing.T) {
+	zone := time.FixedZone("UTC+7", 7*60*60)
+	ny, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	tests := []struct {
+		now      time.Time
+		interval time.Duration
+		offset   time.Duration
+		want     time.Time
+	}{
+		{time.Date(2019, 11, 4, 18, 30, 0, 0, time.UTC), 24 * time.Hour, 0, time.Date(2019, 11, 5, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2019, 11, 4, 18, 30, 0, 0, time.UTC), time.Hour, 0, time.Date(2019, 11, 4, 19, 0, 0, 0, time.UTC)},
+		{time.Date(2019, 11, 4, 19, 0, 0, 0, time.UTC), time.Hour, 0, time.Date(2019, 11, 4, 20, 0, 0, 0, time.UTC)},
+		{time.Date(2019, 11, 4, 1, 30, 0, 0, time.UTC), 24 * time.Hour, 3 * time.Hour, time.Date(2019, 11, 4, 3, 0, 0, 0, time.UTC)},
+		{time.Date(2019, 11, 4, 18, 30, 0, 0, time.UTC), 24 * time.Hour, 3 * time.Hour, time.Date(2019, 11, 5, 3, 0, 0, 0, time.UTC)},
+		{time.Date(2019, 11, 4, 18, 30, 0, 0, zone), 24 * time.Hour, 0, time.Date(2019, 11, 5, 0, 0, 0, 0, zone)},
+		{time.Date(2019, 11, 4, 18, 30, 0, 0, zone), 6 * time.Hour, 0, time.Date(2019, 11, 5, 0, 0, 0, 0, zone)},
+		// Daylight saving time starts and ends
+		{time.Date(2026, 3, 8, 0, 30, 0, 0, ny), 24 * time.Hour, 0, time.Date(2026, 3, 9, 0, 0, 0, 0, ny)},
+		{time.Date(2026, 3, 8, 1, 30, 0, 0, ny), time.Hour, 0, time.Date(2026, 3, 8, 3, 0, 0, 0, ny)},
+		{time.Date(2026, 11, 1, 0, 30, 0, 0, ny), 24 * time.Hour, 0, time.Date(2026, 11, 2, 0, 0, 0, 0, ny)},
+		{time.Date(2026, 11, 1, 12, 0, 0, 0, ny), 24 * time.Hour, 3 * time.Hour, time.Date(2026, 11, 2, 3, 0, 0, 0, ny)},
+		{time.Date(2026, 11, 1, 22, 30, 0, 0, ny), time.Hour, 0, time.Date(2026, 11, 2, 0, 0, 0, 0, ny)},
+		// Interval which doesn't divide a day
+		{time.Date(2026, 3, 8, 10, 0, 0, 0, time.UTC), 7 * time.Hour, 0, time.Date(2026, 3, 8, 14, 0, 0, 0, time.UTC)},
+		{time.Date(2026, 3, 8, 22, 0, 0, 0, time.UTC), 7 * time.Hour, 0, time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC)},
+		// Interval longer than a day
+		{time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC), 168 * time.Hour, 0, time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC), 168 * time.Hour, 0, time.Date(2026, 10, 26, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC), 168 * time.Hour, 3 * time.Hour, time.Date(2026, 10, 19, 3, 0, 0, 0, time.UTC)},
+		{time.Date(2026, 11, 1, 12, 0, 0, 0, ny), 168 * time.Hour, 0, time.Date(2026, 11, 2, 0, 0, 0, 0, ny)},
+		{time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC), 48 * time.Hour, 0, time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC), 25 * time.Hour, 0, time.Date(2026, 10, 16, 19, 0, 0, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		got := nextBoundary(test.now, test.interval, test.offset)
+		assert.True(t, test.want.Equal(got), "expected %v, got %v", test.want, got)
+	}
+}
+
+func TestRotateOnWriteByTime(t *testing.T) {
+	dir := makeTempDir("TestRotateOnWriteByTime", t)
+	defer os.RemoveAll(dir)
+
+	now := time.Date(2019, 11, 4, 23, 59, 0, 0, time.UTC)
+	currentTime = func() time.Time { return now }
+	defer func() { currentTime = time.Now }()
+
+	lf := logFile(dir)
+	l := New(WithLogFile(lf), WithRotateEvery(24*time.Hour))
+	defer l.Close()
+
+	b := []byte("123456789")
+	_, err := l.Write(b)
+	require.NoError(t, err)
+
+	_, err = l.Write(b)
+	require.NoError(t, err)
+	existsWithContent(lf, append(b, b...), t)
+
+	now = now.Add(2 * time.Minute)
+
+	_, err = l.Write(b)
+	require.NoError(t, err)
+	existsWithContent(lf, b, t)
+
+	count, err := getFilesInDir(t, dir)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	// Empty periods are not rotated
+	now = now.Add(48 * time.Hour)
+	require.NoError(t, l.Close())
+	require.NoError(t, os.Truncate(lf, 0))
+
+	_, err = l.Write(b)
+	require.NoError(t, err)
+	existsWithContent(lf, b, t)
+
+	count, err = getFilesInDir(t, dir)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
+
+func TestRotateExistingFromPastPeriod(t *testing.T) {
+	dir := makeTempDir("TestRotateExistingFromPastPeriod", t)
+	defer os.RemoveAll(dir)
+
+	lf := logFile(dir)
+	data := []byte("foo!")
+	require.NoError(t, ioutil.WriteFile(lf, data, 0644))
+
+	yesterday := time.Now().Add(-24 * time.Hour)
+	require.NoError(t, os.Chtimes(lf, yesterday, yesterday))
+
+	l := New(WithLogFile(lf), WithRotateAt(0))
+	defer l.Close()
+
+	b := []byte("123456789")
+	_, err := l.Write(b)
+	require.NoError(t, err)
+	existsWithContent(lf, b, t)
+
+	count, err := getFilesInDir(t, dir)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
+
+func TestRotateByTimer(t *testing.T) {
+	dir := makeTempDir("TestRotateByTimer", t)
+	defer os.RemoveAll(dir)
+
+	lf := logFile(dir)
+	l := New(WithLogFile(lf), WithRotateEvery(50*time.Millisecond))
+	defer l.Close()
+
+	b := []byte("123456789")
+	_, err := l.Write(b)
+	require.NoError(t, err)
+
+	<-time.After(120 * time.Millisecond)
+
+	count, err := getFilesInDir(t, dir)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+	existsWithContent(lf, []byte{}, t)
+
+	// Empty file is not rotated
+	<-time.After(120 * time.Millisecond)
+
+	count, err = getFilesInDir(t, dir)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
+
+func TestExpiryByTimer(t *testing.T) {
+	dir := makeTempDir("TestExpiryByTimer", t)
+	defer os.RemoveAll(dir)
+
+	lf := logFile(dir)
+	prefix, suffix := splitFilename(lf)
+
+	// Expired while logger was not running
+	old := time.Now().Add(-time.Hour).UTC()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, prefix+old.Format(backupTimeFormat)+suffix), []byte("old"), 0644))
+
+	l := New(WithLogFile(lf), WithMaxBytes(10), WithMaxAgeDuration(100*time.Millisecond))
+	defer l.Close()
+
+	b := []byte("123456789")
+	for i := 0; i < 2; i++ {
+		_, err := l.Write(b)
+		require.NoError(t, err)
+	}
+
+	<-time.After(20 * time.Millisecond)
+
+	count, err := getFilesInDir(t, dir)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	// Removed without rotation
+	<-time.After(150 * time.Millisecond)
+
+	count, err = getFilesInDir(t, dir)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	existsWithContent(lf, b, t)
+}
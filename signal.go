@@ -0,0 +1,55 @@
+package rollinglog
+
+import (
+	"os"
+	"os/signal"
+)
+
+// startReopenHandler installs handler for reopen signal if required
+func (l *Logger) startReopenHandler() {
+	if l.reopenSignal == nil || l.signals != nil {
+		return
+	}
+
+	l.signals = make(chan os.Signal, 1)
+	l.signalsDone = make(chan struct{})
+	signal.Notify(l.signals, l.reopenSignal)
+
+	go l.handleReopenSignal(l.signals, l.signalsDone)
+}
+
+func (l *Logger) stopReopenHandler() {
+	if l.signals == nil {
+		return
+	}
+
+	signal.Stop(l.signals)
+	close(l.signalsDone)
+	l.signals = nil
+	l.signalsDone = nil
+}
+
+func (l *Logger) handleReopenSignal(aSignals <-chan os.Signal, aDone <-chan struct{}) {
+	for {
+		select {
+		case <-aSignals:
+			l.reopenOnSignal()
+		case <-aDone:
+			return
+		}
+	}
+}
+
+func (l *Logger) reopenOnSignal() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	// Closed while waiting for lock
+	if l.file == nil {
+		return
+	}
+
+	if err := l.reopen(); err != nil {
+		l.errHandler(err)
+	}
+}
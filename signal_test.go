@@ -0,0 +1,50 @@
+package rollinglog
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReopenOnSignal(t *testing.T) {
+	dir := makeTempDir("TestReopenOnSignal", t)
+	defer os.RemoveAll(dir)
+
+	lf := logFile(dir)
+	l := New(WithLogFile(lf), WithReopenSignal(os.Interrupt))
+	defer l.Close()
+
+	// Handler installed before open, signal is ignored until then
+	require.NotNil(t, l.signals)
+	l.signals <- os.Interrupt
+
+	b := []byte("123456789")
+	_, err := l.Write(b)
+	require.NoError(t, err)
+
+	moved := lf + ".1"
+	require.NoError(t, os.Rename(lf, moved))
+
+	l.signals <- os.Interrupt
+
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(lf)
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+
+	_, err = l.Write(b)
+	require.NoError(t, err)
+	existsWithContent(lf, b, t)
+	existsWithContent(moved, b, t)
+
+	require.NoError(t, l.Close())
+	assert.Nil(t, l.signals)
+
+	// Installed again when closed logger written
+	_, err = l.Write(b)
+	require.NoError(t, err)
+	assert.NotNil(t, l.signals)
+}
@@ -0,0 +1,82 @@
+package rollinglog
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// symlinkTarget returns target of current symlink for active log file.
+// Target is relative to directory of symlink, so both may be moved together.
+func (l *Logger) symlinkTarget() (string, error) {
+	file, err := filepath.Abs(l.filename)
+	if err != nil {
+		return "", errors.Wrapf(err, "can't get absolute path of %s", l.filename)
+	}
+
+	link, err := filepath.Abs(l.symlink)
+	if err != nil {
+		return "", errors.Wrapf(err, "can't get absolute path of %s", l.symlink)
+	}
+
+	return filepath.Rel(filepath.Dir(link), file)
+}
+
+// updateSymlink atomically points current symlink to active log file
+func (l *Logger) updateSymlink() error {
+	if l.symlink == "" {
+		return nil
+	}
+
+	target, err := l.symlinkTarget()
+	if err != nil {
+		return err
+	}
+
+	if current, err := os.Readlink(l.symlink); err == nil && current == target {
+		return nil
+	}
+
+	if err = os.MkdirAll(filepath.Dir(l.symlink), 0755); err != nil {
+		return errors.Wrapf(err, "can't make directories for %s", l.symlink)
+	}
+
+	// New link created aside and renamed over old one, so readers always
+	// see a valid link
+	temp := l.symlink + tempSuffix
+	if err = os.Remove(temp); err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "can't remove %s", temp)
+	}
+	if err = os.Symlink(target, temp); err != nil {
+		return errors.Wrapf(err, "can't create symlink %s", temp)
+	}
+	if err = os.Rename(temp, l.symlink); err != nil {
+		os.Remove(temp)
+		return errors.Wrapf(err, "can't replace symlink %s", l.symlink)
+	}
+
+	return nil
+}
+
+// removeSymlink removes current symlink if it points to active log file
+func (l *Logger) removeSymlink() error {
+	if l.symlink == "" {
+		return nil
+	}
+
+	target, err := l.symlinkTarget()
+	if err != nil {
+		return err
+	}
+
+	if current, err := os.Readlink(l.symlink); err != nil || current != target {
+		// Already removed or points to file of another logger
+		return nil
+	}
+
+	if err = os.Remove(l.symlink); err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "can't remove symlink %s", l.symlink)
+	}
+	return nil
+}
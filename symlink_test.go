@@ -0,0 +1,80 @@
+package rollinglog
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCurrentSymlink(t *testing.T) {
+	dir := makeTempDir("TestCurrentSymlink", t)
+	defer os.RemoveAll(dir)
+
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	currentTime = func() time.Time { return now }
+	defer func() { currentTime = time.Now }()
+
+	link := filepath.Join(dir, "current.log")
+
+	// Stale temporary link of previous run
+	require.NoError(t, os.Symlink("missing.log", link+tempSuffix))
+
+	l := New(WithLogFile(filepath.Join(dir, "app-%Y-%m-%d.log")), WithCurrentSymlink(link))
+
+	_, err := l.Write([]byte("1\n"))
+	require.NoError(t, err)
+
+	target, err := os.Readlink(link)
+	require.NoError(t, err)
+	assert.Equal(t, "app-2026-10-15.log", target)
+
+	now = now.Add(24 * time.Hour)
+	_, err = l.Write([]byte("2\n"))
+	require.NoError(t, err)
+
+	target, err = os.Readlink(link)
+	require.NoError(t, err)
+	assert.Equal(t, "app-2026-10-16.log", target)
+	existsWithContent(link, []byte("2\n"), t)
+
+	require.NoError(t, l.Close())
+
+	_, err = os.Lstat(link)
+	assert.True(t, os.IsNotExist(err), "symlink not removed")
+	_, err = os.Lstat(link + tempSuffix)
+	assert.True(t, os.IsNotExist(err), "temporary symlink not removed")
+}
+
+func TestCurrentSymlinkOtherDir(t *testing.T) {
+	dir := makeTempDir("TestCurrentSymlinkOtherDir", t)
+	defer os.RemoveAll(dir)
+
+	lf := filepath.Join(dir, "logs", "app.log")
+	link := filepath.Join(dir, "links", "current.log")
+	l := New(WithLogFile(lf), WithCurrentSymlink(link))
+
+	_, err := l.Write([]byte("1\n"))
+	require.NoError(t, err)
+
+	target, err := os.Readlink(link)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join("..", "logs", "app.log"), target)
+
+	data, err := ioutil.ReadFile(link)
+	require.NoError(t, err)
+	assert.Equal(t, "1\n", string(data))
+
+	// Link replaced by another logger is kept
+	require.NoError(t, os.Remove(link))
+	require.NoError(t, os.Symlink("other.log", link))
+	require.NoError(t, l.Close())
+
+	target, err = os.Readlink(link)
+	require.NoError(t, err)
+	assert.Equal(t, "other.log", target)
+}
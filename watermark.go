@@ -0,0 +1,173 @@
+package rollinglog
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DegradedMode defines how to handle writes while free space is low
+type DegradedMode int
+
+const (
+	// DropWrites silently drops writes
+	DropWrites DegradedMode = iota
+	// TruncateLog truncates log file and continues writing into it
+	TruncateLog
+	// WriteStderr writes data to stderr instead of log file
+	WriteStderr
+)
+
+// diskFree returns free space on file system of aPath. Replaced in tests.
+var diskFree = diskFreeSpace
+
+// stderr used as fallback output. Replaced in tests.
+var stderr io.Writer = os.Stderr
+
+// spaceCheckInterval limits how often free space checked on write
+const spaceCheckInterval = time.Second
+
+// writeDegraded checks free space and handles aData according to degraded
+// mode when space is low. Returns false when aData should be written as usual.
+func (l *Logger) writeDegraded(p []byte) (bool, int, error) {
+	if !l.checkFreeSpace() {
+		return false, 0, nil
+	}
+
+	switch l.degradedMode {
+	case DropWrites:
+		return true, len(p), nil
+	case WriteStderr:
+		n, err := stderr.Write(p)
+		return true, n, err
+	}
+
+	return false, 0, nil
+}
+
+// checkFreeSpace checks free space on log file system not often than
+// spaceCheckInterval and switches degraded mode with hysteresis. Returns true
+// in degraded mode.
+func (l *Logger) checkFreeSpace() bool {
+	if l.lowWatermark == 0 {
+		return false
+	}
+
+	now := currentTime()
+	if !l.lastSpaceCheck.IsZero() && now.Sub(l.lastSpaceCheck) < spaceCheckInterval {
+		return l.degraded
+	}
+	l.lastSpaceCheck = now
+
+	dir := filepath.Dir(l.filename)
+	free, err := diskFree(dir)
+	if os.IsNotExist(err) {
+		// Nothing written yet
+		return l.degraded
+	}
+	if err != nil {
+		l.errHandler(errors.Wrapf(err, "can't check free space on %s", dir))
+		return l.degraded
+	}
+
+	switch {
+	case l.degraded && free >= l.highWatermark:
+		l.degraded = false
+		l.errHandler(errors.Errorf("free space on %s recovered to %d bytes, leaving degraded mode", dir, free))
+	case !l.degraded && free < l.lowWatermark:
+		// Backups pruned by sweeper, so writers don't wait for it
+		l.degraded = true
+		l.errHandler(errors.Errorf("free space on %s is %d bytes, entering degraded mode", dir, free))
+		atomic.StoreInt32(&l.pruning, 1)
+		l.runSweeping()
+	}
+
+	if l.degraded && l.degradedMode == TruncateLog {
+		if err := l.truncate(); err != nil {
+			l.errHandler(err)
+		}
+	}
+
+	return l.degraded
+}
+
+// pruneBackups removes oldest backups until free space on log file system
+// reaches high watermark if pruning requested. Should be called holding
+// sweeping lock.
+func (l *Logger) pruneBackups() {
+	if !atomic.CompareAndSwapInt32(&l.pruning, 1, 0) {
+		return
+	}
+
+	dir := filepath.Dir(l.logFilename())
+	removed := []string{}
+
+	err := func() error {
+		free, err := diskFree(dir)
+		if err != nil {
+			return errors.Wrapf(err, "can't check free space on %s", dir)
+		}
+
+		l.backupLock.Lock()
+		backups, err := l.backups()
+		l.backupLock.Unlock()
+		if err != nil {
+			return err
+		}
+
+		root := l.backupsRoot()
+		for len(backups) > 0 && free < l.highWatermark {
+			b := backups[len(backups)-1]
+			backups = backups[:len(backups)-1]
+
+			// Archived backups removed too, pruning doesn't bypass archiver
+			r := removal{filepath.Join(root, b.name), b.size, RemoveFreeSpace}
+			if l.archiver != nil {
+				err = l.archiveBackup(r)
+			} else {
+				err = l.removeBackup(r, false)
+			}
+			if err != nil {
+				return err
+			}
+			removed = append(removed, r.path)
+
+			if free, err = diskFree(dir); err != nil {
+				return errors.Wrapf(err, "can't check free space on %s", dir)
+			}
+		}
+
+		return nil
+	}()
+
+	if err != nil {
+		l.errHandler(err)
+	}
+	if len(removed) > 0 {
+		l.errHandler(errors.Errorf("free space on %s is low, removed backups %v", dir, removed))
+	}
+}
+
+// truncate drops content of opened log file
+func (l *Logger) truncate() error {
+	if l.file == nil {
+		return nil
+	}
+
+	if err := l.flushFile(); err != nil {
+		return err
+	}
+
+	if err := l.file.Truncate(0); err != nil {
+		return errors.Wrapf(err, "can't truncate %s", l.filename)
+	}
+
+	l.state.Size = 0
+	l.state.Lines = 0
+	l.unsynced = 0
+	return nil
+}
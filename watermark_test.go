@@ -0,0 +1,148 @@
+package rollinglog
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockDiskFree makes free space equal to aCapacity minus size of files in
+// directory
+func mockDiskFree(t testing.TB, aCapacity uint64) func() {
+	diskFree = func(aDir string) (uint64, error) {
+		files, err := ioutil.ReadDir(aDir)
+		require.NoError(t, err)
+
+		used := uint64(0)
+		for _, f := range files {
+			used += uint64(f.Size())
+		}
+		if used > aCapacity {
+			return 0, nil
+		}
+		return aCapacity - used, nil
+	}
+
+	return func() { diskFree = diskFreeSpace }
+}
+
+func TestFreeSpacePrune(t *testing.T) {
+	dir := makeTempDir("TestFreeSpacePrune", t)
+	defer os.RemoveAll(dir)
+	defer mockDiskFree(t, 50)()
+
+	errs := []string{}
+	lf := logFile(dir)
+	l := New(WithLogFile(lf), WithMaxBytes(10), WithFreeSpaceWatermark(15, 25, DropWrites),
+		WithErrorHandler(func(err error) { errs = append(errs, err.Error()) }))
+	defer l.Close()
+
+	b := []byte("123456789")
+	for i := 0; i < 4; i++ {
+		_, err := l.Write(b)
+		require.NoError(t, err)
+		<-time.After(time.Millisecond * 2)
+	}
+
+	// 36 bytes used, 14 free: degraded mode entered right away, two oldest
+	// backups removed by sweeper
+	l.lastSpaceCheck = time.Time{}
+	_, err := l.Write(b)
+	require.NoError(t, err)
+	assert.True(t, l.degraded)
+	l.wg.Wait()
+
+	require.Equal(t, 2, len(errs))
+	assert.Contains(t, errs[0], "entering degraded mode")
+	assert.Contains(t, errs[1], "is low, removed backups")
+
+	count, err := getFilesInDir(t, dir)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	// Degraded mode left on next check
+	l.lastSpaceCheck = time.Time{}
+	_, err = l.Write(b)
+	require.NoError(t, err)
+
+	assert.False(t, l.degraded)
+	require.Equal(t, 3, len(errs))
+	assert.Contains(t, errs[2], "leaving degraded mode")
+
+	// Backup left after pruning and one rotated by write
+	count, err = getFilesInDir(t, dir)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+}
+
+func TestDegradedModes(t *testing.T) {
+	dir := makeTempDir("TestDegradedModes", t)
+	defer os.RemoveAll(dir)
+
+	free := uint64(0)
+	diskFree = func(aDir string) (uint64, error) { return free, nil }
+	defer func() { diskFree = diskFreeSpace }()
+
+	out := &bytes.Buffer{}
+	stderr = out
+	defer func() { stderr = os.Stderr }()
+
+	tests := []struct {
+		mode    DegradedMode
+		content string
+		stderr  string
+	}{
+		{DropWrites, "1\n3\n", ""},
+		// Truncated on each check in degraded mode
+		{TruncateLog, "3\n", ""},
+		{WriteStderr, "1\n3\n", "2\n"},
+	}
+
+	for _, test := range tests {
+		errs := []string{}
+		lf := filepath.Join(dir, fmt.Sprintf("foobar-%d.log", test.mode))
+		l := New(WithLogFile(lf), WithFreeSpaceWatermark(10, 20, test.mode),
+			WithErrorHandler(func(err error) { errs = append(errs, err.Error()) }))
+
+		free = 100
+		out.Reset()
+
+		_, err := l.Write([]byte("1\n"))
+		require.NoError(t, err)
+
+		// Low free space
+		free = 5
+		l.lastSpaceCheck = time.Time{}
+		n, err := l.Write([]byte("2\n"))
+		require.NoError(t, err)
+		assert.Equal(t, 2, n)
+		assert.True(t, l.degraded)
+
+		// Hysteresis: still degraded between watermarks
+		free = 15
+		l.lastSpaceCheck = time.Time{}
+		assert.True(t, l.checkFreeSpace())
+
+		// Recovered
+		free = 20
+		l.lastSpaceCheck = time.Time{}
+		_, err = l.Write([]byte("3\n"))
+		require.NoError(t, err)
+		assert.False(t, l.degraded)
+		require.NoError(t, l.Close())
+
+		existsWithContent(lf, []byte(test.content), t)
+		assert.Equal(t, test.stderr, out.String())
+
+		require.Equal(t, 2, len(errs), test.mode)
+		assert.Contains(t, errs[0], "entering degraded mode")
+		assert.Contains(t, errs[1], "leaving degraded mode")
+	}
+}